@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"log"
+	_ "time/tzdata" // Embed the time zone database for hosts without one
 
 	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	appmiddleware "github.com/devlpr-nitish/appointment-booking-go/internal/middleware"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/payments"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/routes"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
 	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
@@ -25,7 +30,7 @@ func main() {
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
 		AllowOrigins: []string{"http://localhost:3000", "https://appointment-booking-next.vercel.app"},
 		AllowMethods: []string{echo.GET, echo.POST, echo.PUT, echo.PATCH, echo.DELETE, echo.OPTIONS},
-		AllowHeaders: []string{echo.HeaderContentType, echo.HeaderAuthorization},
+		AllowHeaders: []string{echo.HeaderContentType, echo.HeaderAuthorization, appmiddleware.HeaderIdempotencyKey},
 	}))
 
 	db := database.Connect(cfg)
@@ -36,6 +41,15 @@ func main() {
 	}
 	defer sqlDB.Close()
 
+	// The fake provider is always available for development; real providers register here too
+	payments.Register(payments.NewFakeProvider(cfg.PaymentWebhookSecret, cfg.PaymentWebhookTolerance))
+	if _, err := payments.Get(cfg.PaymentProvider); err != nil {
+		log.Fatalf("Invalid payment provider: %v", err)
+	}
+
+	// Release bookings and reservations that were not acted on in time
+	services.StartExpirySweeper(context.Background(), cfg.ExpirySweepInterval)
+
 	routes.Routes(e)
 
 	log.Printf("Server is running on http://localhost:%s", cfg.AppPort)
@@ -1,21 +1,73 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 type Config struct {
 	DBDriver string
 	DBUrl    string
 	AppPort  string
+
+	// PendingBookingTTL is how long an expert has to accept a booking request
+	PendingBookingTTL time.Duration
+	// SlotHoldTTL is how long a time is reserved for a user during checkout
+	SlotHoldTTL time.Duration
+	// WaitlistOfferTTL is how long a waitlisted user has to claim a freed time
+	WaitlistOfferTTL time.Duration
+	// PaymentTTL is how long a booking awaiting payment holds its time
+	PaymentTTL time.Duration
+	// IdempotencyKeyTTL is how long stored responses are replayed for retries
+	IdempotencyKeyTTL time.Duration
+	// ExpirySweepInterval is how often expired bookings are released
+	ExpirySweepInterval time.Duration
+
+	// PaymentProvider is the name of the provider new payments are collected with
+	PaymentProvider string
+	// DefaultCurrency is the ISO 4217 code experts charge in unless they choose another
+	DefaultCurrency string
+	// TaxRateBasisPoints is the tax added to session prices in hundredths of a
+	// percent, e.g. 1900 for 19%
+	TaxRateBasisPoints int64
+	// PaymentWebhookSecret signs provider webhooks; webhooks are rejected while it is empty
+	PaymentWebhookSecret string
+	// PaymentWebhookTolerance is how old a webhook signature may be before it is
+	// rejected as a possible replay
+	PaymentWebhookTolerance time.Duration
 }
 
+var current *Config
+
 func LoadConfig() *Config {
-	return &Config{
-		DBDriver: getEnv("DB_DRIVER", "postgres"), // Default to postgres
-		DBUrl:    getEnv("DB_URL", ""),
-		AppPort:  getEnv("PORT", "8080"),
+	current = &Config{
+		DBDriver:            getEnv("DB_DRIVER", "postgres"), // Default to postgres
+		DBUrl:               getEnv("DB_URL", ""),
+		AppPort:             getEnv("PORT", "8080"),
+		PendingBookingTTL:   getEnvDuration("PENDING_BOOKING_TTL", 24*time.Hour),
+		SlotHoldTTL:         getEnvDuration("SLOT_HOLD_TTL", 10*time.Minute),
+		WaitlistOfferTTL:    getEnvDuration("WAITLIST_OFFER_TTL", 30*time.Minute),
+		PaymentTTL:          getEnvDuration("PAYMENT_TTL", 15*time.Minute),
+		IdempotencyKeyTTL:   getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		ExpirySweepInterval: getEnvDuration("EXPIRY_SWEEP_INTERVAL", time.Minute),
+		PaymentProvider:     getEnv("PAYMENT_PROVIDER", "fake"),
+		DefaultCurrency:     getEnv("DEFAULT_CURRENCY", "USD"),
+		TaxRateBasisPoints:  getEnvInt("TAX_RATE_BASIS_POINTS", 0),
+
+		PaymentWebhookSecret:    getEnv("PAYMENT_WEBHOOK_SECRET", ""),
+		PaymentWebhookTolerance: getEnvDuration("PAYMENT_WEBHOOK_TOLERANCE", 5*time.Minute),
 	}
+	return current
+}
+
+// GetConfig returns the loaded configuration, loading it on first use
+func GetConfig() *Config {
+	if current == nil {
+		return LoadConfig()
+	}
+	return current
 }
 
 func getEnv(key, fallback string) string {
@@ -24,3 +76,33 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// getEnvDuration parses values such as "30m" or "24h"
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("Invalid duration for %s, using default %s", key, fallback)
+		return fallback
+	}
+	return duration
+}
+
+// getEnvInt parses non-negative integers
+func getEnvInt(key string, fallback int64) int64 {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		log.Printf("Invalid integer for %s, using default %d", key, fallback)
+		return fallback
+	}
+	return parsed
+}
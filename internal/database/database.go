@@ -40,9 +40,19 @@ func Connect(cfg *config.Config) *gorm.DB {
 	err = db.AutoMigrate(
 		&models.User{},
 		&models.Expert{},
+		&models.Offering{},
 		&models.AvailabilitySlot{},
+		&models.AvailabilityOverride{},
+		&models.BookingSeries{},
 		&models.Booking{},
+		&models.BookingTransition{},
+		&models.SlotHold{},
+		&models.WaitlistEntry{},
+		&models.IdempotencyKey{},
+		&models.CancellationPolicyTier{},
 		&models.Payment{},
+		&models.PaymentRefund{},
+		&models.PaymentEvent{},
 		&models.Review{},
 	)
 
@@ -50,6 +60,10 @@ func Connect(cfg *config.Config) *gorm.DB {
 		log.Fatalf("Failed to migrate models: %v", err)
 	}
 
+	if err := runMigrations(db); err != nil {
+		log.Fatalf("Failed to run migrations: %v", err)
+	}
+
 	DB = db
 
 	log.Println("Db connected successfully")
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// schemaMigration records a migration that has already been applied
+type schemaMigration struct {
+	ID        string    `gorm:"primaryKey"`
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type migration struct {
+	ID string
+	Up func(tx *gorm.DB) error
+}
+
+// migrations run in order after AutoMigrate, each exactly once. They cover the schema
+// changes AutoMigrate cannot express, such as dropping indexes or adding constraints.
+// Never edit a migration that has shipped; append a new one instead.
+var migrations = []migration{
+	{ID: "0001_drop_booking_unique_indexes", Up: dropBookingUniqueIndexes},
+	{ID: "0002_booking_overlap_constraint", Up: addBookingOverlapConstraint},
+	{ID: "0003_release_declined_and_expired_bookings", Up: releaseDeclinedAndExpiredBookings},
+	{ID: "0004_booking_slot_on_delete_set_null", Up: setBookingSlotOnDeleteSetNull},
+	{ID: "0005_pad_availability_clock_times", Up: padAvailabilityClockTimes},
+	{ID: "0006_group_session_overlap", Up: allowGroupSessionOverlap},
+	{ID: "0007_money_minor_units", Up: convertMoneyToMinorUnits},
+}
+
+func runMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		var count int64
+		if err := db.Model(&schemaMigration{}).Where("id = ?", m.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{ID: m.ID}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s failed: %w", m.ID, err)
+		}
+
+		log.Printf("Applied migration %s", m.ID)
+	}
+
+	return nil
+}
+
+// dropBookingUniqueIndexes removes the single-column unique indexes that allowed only
+// one booking per user, per expert and per availability slot
+func dropBookingUniqueIndexes(tx *gorm.DB) error {
+	for _, index := range []string{"idx_bookings_user_id", "idx_bookings_expert_id", "idx_bookings_slot_id"} {
+		if err := tx.Exec("DROP INDEX IF EXISTS " + index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addBookingOverlapConstraint prevents two active bookings of the same expert from
+// overlapping in time. Bookings created before start_at existed are ignored.
+func addBookingOverlapConstraint(tx *gorm.DB) error {
+	if err := tx.Exec("CREATE EXTENSION IF NOT EXISTS btree_gist").Error; err != nil {
+		return err
+	}
+	return tx.Exec(`ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+		expert_id WITH =,
+		tstzrange(start_at, end_at) WITH &&
+	) WHERE (status NOT IN ('cancelled') AND start_at IS NOT NULL)`).Error
+}
+
+// releaseDeclinedAndExpiredBookings stops declined and expired bookings from blocking
+// the expert's time
+func releaseDeclinedAndExpiredBookings(tx *gorm.DB) error {
+	if err := tx.Exec("ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap").Error; err != nil {
+		return err
+	}
+	return tx.Exec(`ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+		expert_id WITH =,
+		tstzrange(start_at, end_at) WITH &&
+	) WHERE (status NOT IN ('cancelled', 'declined', 'expired') AND start_at IS NOT NULL)`).Error
+}
+
+// setBookingSlotOnDeleteSetNull lets weekly rules be deleted while bookings still refer
+// to them. Databases created before the booking's slot became optional have the
+// foreign key without an ON DELETE action.
+func setBookingSlotOnDeleteSetNull(tx *gorm.DB) error {
+	if err := tx.Exec("ALTER TABLE bookings DROP CONSTRAINT IF EXISTS fk_bookings_slot").Error; err != nil {
+		return err
+	}
+	return tx.Exec(`ALTER TABLE bookings ADD CONSTRAINT fk_bookings_slot
+		FOREIGN KEY (slot_id) REFERENCES availability_slots(id) ON DELETE SET NULL`).Error
+}
+
+// padAvailabilityClockTimes rewrites clock times such as "9:00", accepted before
+// strict validation, as "09:00"
+func padAvailabilityClockTimes(tx *gorm.DB) error {
+	for _, column := range []string{"start_time", "end_time"} {
+		if err := tx.Exec("UPDATE availability_slots SET " + column + " = '0' || " + column +
+			" WHERE " + column + " ~ '^[0-9]:[0-5][0-9]$'").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowGroupSessionOverlap lets the seats of a group session, which share a
+// session_key, overlap each other while still excluding every other overlap.
+// Individual bookings have no session key and fall back to their own ID.
+func allowGroupSessionOverlap(tx *gorm.DB) error {
+	if err := tx.Exec("ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap").Error; err != nil {
+		return err
+	}
+	return tx.Exec(`ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap EXCLUDE USING gist (
+		expert_id WITH =,
+		tstzrange(start_at, end_at) WITH &&,
+		(COALESCE(session_key, id::text)) WITH <>
+	) WHERE (status NOT IN ('cancelled', 'declined', 'expired') AND start_at IS NOT NULL)`).Error
+}
+
+// convertMoneyToMinorUnits moves the float hourly rates and payment amounts into the
+// integer minor unit columns and drops the float columns. Amounts stored before
+// currencies existed are in US dollars.
+func convertMoneyToMinorUnits(tx *gorm.DB) error {
+	migrator := tx.Migrator()
+
+	if migrator.HasColumn("experts", "hourly_rate") {
+		if err := tx.Exec(`UPDATE experts SET hourly_rate_minor = ROUND(hourly_rate::numeric * 100),
+			hourly_rate_currency = 'USD' WHERE hourly_rate IS NOT NULL`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("ALTER TABLE experts DROP COLUMN hourly_rate").Error; err != nil {
+			return err
+		}
+	}
+
+	if migrator.HasColumn("payments", "amount") {
+		// Payments taken since the checkout was added carry their currency, which
+		// decides the size of the minor unit
+		columns := []string{"id", "amount"}
+		hasCurrency := migrator.HasColumn("payments", "currency")
+		if hasCurrency {
+			columns = append(columns, "currency")
+		}
+
+		var rows []struct {
+			ID       uint
+			Amount   float64
+			Currency string
+		}
+		if err := tx.Table("payments").Select(columns).Where("amount IS NOT NULL").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			currency, err := models.NormalizeCurrency(row.Currency)
+			if err != nil {
+				currency = "USD"
+			}
+			amount := models.MoneyFromMajor(row.Amount, currency)
+			if err := tx.Table("payments").Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"amount_minor":    amount.Minor,
+				"amount_currency": amount.Currency,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Exec("ALTER TABLE payments DROP COLUMN amount").Error; err != nil {
+			return err
+		}
+		if hasCurrency {
+			if err := tx.Exec("ALTER TABLE payments DROP COLUMN currency").Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -11,15 +12,30 @@ import (
 )
 
 type CreateAvailabilityRequest struct {
-	DayOfWeek int    `json:"day_of_week" validate:"required,min=0,max=6"`
-	StartTime string `json:"start_time" validate:"required"`
-	EndTime   string `json:"end_time" validate:"required"`
+	// A pointer so that Sunday (0) passes the required check
+	DayOfWeek       *int   `json:"day_of_week" validate:"required,min=0,max=6"`
+	StartTime       string `json:"start_time" validate:"required,clock"`
+	EndTime         string `json:"end_time" validate:"required,clock"`
+	CrossesMidnight bool   `json:"crosses_midnight"`
 }
 
 type UpdateAvailabilityRequest struct {
-	DayOfWeek int    `json:"day_of_week" validate:"required,min=0,max=6"`
-	StartTime string `json:"start_time" validate:"required"`
-	EndTime   string `json:"end_time" validate:"required"`
+	// A pointer so that Sunday (0) passes the required check
+	DayOfWeek       *int   `json:"day_of_week" validate:"required,min=0,max=6"`
+	StartTime       string `json:"start_time" validate:"required,clock"`
+	EndTime         string `json:"end_time" validate:"required,clock"`
+	CrossesMidnight bool   `json:"crosses_midnight"`
+}
+
+type WeeklyWindowRequest struct {
+	DayOfWeek       int    `json:"day_of_week"`
+	StartTime       string `json:"start_time"`
+	EndTime         string `json:"end_time"`
+	CrossesMidnight bool   `json:"crosses_midnight"`
+}
+
+type ReplaceScheduleRequest struct {
+	Windows []WeeklyWindowRequest `json:"windows"`
 }
 
 // CreateAvailability creates a new availability slot for the authenticated expert
@@ -44,9 +60,9 @@ func CreateAvailability(c echo.Context) error {
 		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
 	}
 
-	availability, err := services.CreateAvailability(expert.ID, req.DayOfWeek, req.StartTime, req.EndTime)
+	availability, err := services.CreateAvailability(expert.ID, *req.DayOfWeek, req.StartTime, req.EndTime, req.CrossesMidnight)
 	if err != nil {
-		return utils.RespondError(c, http.StatusBadRequest, err, "failed to create availability")
+		return respondScheduleError(c, err, "failed to create availability")
 	}
 
 	return utils.RespondSuccess(c, http.StatusCreated, "availability created successfully", availability)
@@ -105,9 +121,9 @@ func UpdateAvailability(c echo.Context) error {
 		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
 	}
 
-	availability, err := services.UpdateAvailability(uint(id), expert.ID, req.DayOfWeek, req.StartTime, req.EndTime)
+	availability, err := services.UpdateAvailability(uint(id), expert.ID, *req.DayOfWeek, req.StartTime, req.EndTime, req.CrossesMidnight)
 	if err != nil {
-		return utils.RespondError(c, http.StatusBadRequest, err, "failed to update availability")
+		return respondScheduleError(c, err, "failed to update availability")
 	}
 
 	return utils.RespondSuccess(c, http.StatusOK, "availability updated successfully", availability)
@@ -137,13 +153,54 @@ func DeleteAvailability(c echo.Context) error {
 	}
 
 	if err := services.DeleteAvailability(uint(id), expert.ID); err != nil {
-		return utils.RespondError(c, http.StatusBadRequest, err, "failed to delete availability")
+		return respondScheduleError(c, err, "failed to delete availability")
 	}
 
 	return utils.RespondSuccess(c, http.StatusOK, "availability deleted successfully", nil)
 }
 
-// GetAvailableSlots returns available time slots for an expert on a specific date
+// ReplaceSchedule replaces the authenticated expert's whole weekly schedule. Invalid
+// windows are reported by their index in the request.
+func ReplaceSchedule(c echo.Context) error {
+	var req ReplaceScheduleRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	windows := make([]services.WeeklyWindow, len(req.Windows))
+	for i, window := range req.Windows {
+		windows[i] = services.WeeklyWindow{
+			DayOfWeek:       window.DayOfWeek,
+			StartTime:       window.StartTime,
+			EndTime:         window.EndTime,
+			CrossesMidnight: window.CrossesMidnight,
+		}
+	}
+
+	schedule, err := services.ReplaceWeeklySchedule(expert.ID, windows)
+	if err != nil {
+		var report *services.ScheduleValidationError
+		if errors.As(err, &report) {
+			return utils.RespondError(c, http.StatusBadRequest, err, report.Windows)
+		}
+		return respondScheduleError(c, err, "failed to replace schedule")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "schedule replaced successfully", schedule)
+}
+
+// GetAvailableSlots returns available time slots for an expert on a specific date,
+// optionally for one of the expert's offerings and in the caller's time zone
 func GetAvailableSlots(c echo.Context) error {
 	expertIDStr := c.QueryParam("expertId")
 	if expertIDStr == "" {
@@ -160,12 +217,130 @@ func GetAvailableSlots(c echo.Context) error {
 		return utils.RespondError(c, http.StatusBadRequest, nil, "date query parameter is required")
 	}
 
-	slots, err := services.GetAvailableSlots(uint(expertID), date)
+	// Optional: generate slots for a specific offering's session length
+	var offeringID uint64
+	if offeringIDStr := c.QueryParam("offeringId"); offeringIDStr != "" {
+		offeringID, err = strconv.ParseUint(offeringIDStr, 10, 32)
+		if err != nil {
+			return utils.RespondError(c, http.StatusBadRequest, err, "invalid offeringId")
+		}
+	}
+
+	// Optional: IANA time zone of the date and of the returned times
+	tz := c.QueryParam("tz")
+
+	slots, err := services.GetAvailableSlots(uint(expertID), date, uint(offeringID), tz)
 	if err != nil {
-		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to get available slots")
+		return respondAvailabilityError(c, err, "failed to get available slots")
 	}
 
 	return utils.RespondSuccess(c, http.StatusOK, "available slots retrieved successfully", map[string]interface{}{
 		"slots": slots,
 	})
 }
+
+// GetAvailabilityRange returns the slots of an expert for every day between the from
+// and to dates, for calendar views. Pass summary=true to only get the per-day flags.
+func GetAvailabilityRange(c echo.Context) error {
+	expertID, err := strconv.ParseUint(c.QueryParam("expertId"), 10, 32)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid expertId")
+	}
+
+	from, to := c.QueryParam("from"), c.QueryParam("to")
+	if from == "" || to == "" {
+		return utils.RespondError(c, http.StatusBadRequest, nil, "from and to query parameters are required")
+	}
+
+	var offeringID uint64
+	if offeringIDStr := c.QueryParam("offeringId"); offeringIDStr != "" {
+		offeringID, err = strconv.ParseUint(offeringIDStr, 10, 32)
+		if err != nil {
+			return utils.RespondError(c, http.StatusBadRequest, err, "invalid offeringId")
+		}
+	}
+
+	days, err := services.GetAvailabilityRange(uint(expertID), from, to, uint(offeringID), c.QueryParam("tz"))
+	if err != nil {
+		return respondAvailabilityError(c, err, "failed to get availability")
+	}
+
+	if c.QueryParam("summary") == "true" {
+		for i := range days {
+			days[i].Slots = nil
+		}
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "availability retrieved successfully", map[string]interface{}{
+		"days": days,
+	})
+}
+
+// GetNextAvailable returns the experts of a category ordered by their earliest free
+// session, optionally within an hourly rate range in a currency and between the from
+// and to dates
+func GetNextAvailable(c echo.Context) error {
+	category := c.QueryParam("category")
+	if category == "" {
+		return utils.RespondError(c, http.StatusBadRequest, nil, "category query parameter is required")
+	}
+
+	query := services.NextAvailableQuery{
+		Category: category,
+		From:     c.QueryParam("from"),
+		To:       c.QueryParam("to"),
+		TimeZone: c.QueryParam("tz"),
+		Currency: c.QueryParam("currency"),
+	}
+
+	var err error
+	// Rate bounds are in minor units of the currency, e.g. 5000 for 50.00 USD
+	if minRate := c.QueryParam("minRateMinor"); minRate != "" {
+		if query.MinRateMinor, err = strconv.ParseInt(minRate, 10, 64); err != nil {
+			return utils.RespondError(c, http.StatusBadRequest, err, "invalid minRateMinor")
+		}
+	}
+	if maxRate := c.QueryParam("maxRateMinor"); maxRate != "" {
+		if query.MaxRateMinor, err = strconv.ParseInt(maxRate, 10, 64); err != nil {
+			return utils.RespondError(c, http.StatusBadRequest, err, "invalid maxRateMinor")
+		}
+	}
+
+	query.Limit, _ = strconv.Atoi(c.QueryParam("limit"))
+	if query.Limit < 1 || query.Limit > 100 {
+		query.Limit = 20
+	}
+
+	experts, err := services.FindNextAvailable(query)
+	if err != nil {
+		return respondAvailabilityError(c, err, "failed to find available experts")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "available experts retrieved successfully", map[string]interface{}{
+		"experts": experts,
+	})
+}
+
+// respondAvailabilityError reports errors caused by the query, such as an unknown time
+// zone or an offering of another expert, as 400 and everything else as 500
+func respondAvailabilityError(c echo.Context, err error, details string) error {
+	var inputErr *services.InputError
+	if errors.As(err, &inputErr) {
+		return utils.RespondError(c, http.StatusBadRequest, err, details)
+	}
+	return utils.RespondError(c, http.StatusInternalServerError, err, details)
+}
+
+// respondScheduleError reports invalid windows as 400, slots of other experts as 404
+// and everything else as 500
+func respondScheduleError(c echo.Context, err error, details string) error {
+	var inputErr *services.InputError
+	switch {
+	case errors.As(err, &inputErr):
+		return utils.RespondError(c, http.StatusBadRequest, err, details)
+	case errors.Is(err, services.ErrAvailabilityNotFound):
+		return utils.RespondError(c, http.StatusNotFound, err, details)
+	default:
+		return utils.RespondError(c, http.StatusInternalServerError, err, details)
+	}
+}
@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type CreateAvailabilityOverrideRequest struct {
+	Kind      string `json:"kind" validate:"required,oneof=blackout block extra"`
+	Date      string `json:"date" validate:"required"`
+	EndDate   string `json:"end_date"`
+	StartTime string `json:"start_time" validate:"omitempty,clock"`
+	EndTime   string `json:"end_time" validate:"omitempty,clock"`
+	Reason    string `json:"reason" validate:"max=255"`
+}
+
+// CreateAvailabilityOverride adds a blackout, block or extra window to the
+// authenticated expert's availability
+func CreateAvailabilityOverride(c echo.Context) error {
+	var req CreateAvailabilityOverrideRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	override, err := services.CreateAvailabilityOverride(expert.ID, services.AvailabilityOverrideInput{
+		Kind:      models.OverrideKind(req.Kind),
+		Date:      req.Date,
+		EndDate:   req.EndDate,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Reason:    req.Reason,
+	})
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "failed to create availability override")
+	}
+
+	return utils.RespondSuccess(c, http.StatusCreated, "availability override created successfully", override)
+}
+
+// GetAvailabilityOverrides lists the authenticated expert's overrides, optionally
+// between the from and to dates
+func GetAvailabilityOverrides(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	overrides, err := services.GetAvailabilityOverrides(expert.ID, c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "failed to get availability overrides")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "availability overrides retrieved successfully", overrides)
+}
+
+// DeleteAvailabilityOverride removes one of the authenticated expert's overrides
+func DeleteAvailabilityOverride(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid availability override id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	if err := services.DeleteAvailabilityOverride(uint(id), expert.ID); err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "failed to delete availability override")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "availability override deleted successfully", nil)
+}
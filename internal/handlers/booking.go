@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
@@ -10,8 +15,47 @@ import (
 )
 
 type CreateBookingRequest struct {
-	ExpertID uint `json:"expert_id" validate:"required"`
-	SlotID   uint `json:"slot_id" validate:"required"`
+	ExpertID   uint   `json:"expert_id" validate:"required"`
+	OfferingID uint   `json:"offering_id"`                          // Optional, defaults to the expert's default session
+	Date       string `json:"date" validate:"required"`             // Format: "YYYY-MM-DD"
+	StartTime  string `json:"start_time" validate:"required,clock"` // Format: "HH:MM"
+	TimeZone   string `json:"time_zone"`                            // Optional IANA name, defaults to the expert's time zone
+}
+
+type CreateSlotHoldRequest struct {
+	ExpertID   uint   `json:"expert_id" validate:"required"`
+	OfferingID uint   `json:"offering_id"`                          // Optional, defaults to the expert's default session
+	Date       string `json:"date" validate:"required"`             // Format: "YYYY-MM-DD"
+	StartTime  string `json:"start_time" validate:"required,clock"` // Format: "HH:MM"
+	TimeZone   string `json:"time_zone"`                            // Optional IANA name, defaults to the expert's time zone
+}
+
+type BookingTransitionRequest struct {
+	Reason string `json:"reason"`
+}
+
+type CancelBookingRequest struct {
+	Reason string `json:"reason"`
+	// Scope "following" also cancels the later occurrences of the booking's series
+	Scope string `json:"scope" validate:"omitempty,oneof=this following"`
+}
+
+type CreateBookingSeriesRequest struct {
+	ExpertID   uint   `json:"expert_id" validate:"required"`
+	OfferingID uint   `json:"offering_id"`                          // Optional, defaults to the expert's default session
+	Date       string `json:"date" validate:"required"`             // Format: "YYYY-MM-DD", first occurrence
+	StartTime  string `json:"start_time" validate:"required,clock"` // Format: "HH:MM"
+	TimeZone   string `json:"time_zone"`                            // Optional IANA name, defaults to the expert's time zone
+	Frequency  string `json:"frequency" validate:"required,oneof=weekly biweekly"`
+	Count      int    `json:"count" validate:"omitempty,min=1,max=52"` // Either count or until
+	Until      string `json:"until"`                                   // Format: "YYYY-MM-DD", inclusive
+}
+
+type RescheduleBookingRequest struct {
+	Date      string `json:"date" validate:"required"`             // Format: "YYYY-MM-DD"
+	StartTime string `json:"start_time" validate:"required,clock"` // Format: "HH:MM"
+	TimeZone  string `json:"time_zone"`                            // Optional IANA name, defaults to the expert's time zone
+	Reason    string `json:"reason"`
 }
 
 func CreateBooking(c echo.Context) error {
@@ -20,15 +64,439 @@ func CreateBooking(c echo.Context) error {
 		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
 	}
 
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
 	user, ok := c.Get("user").(*models.User)
 	if !ok {
 		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
 	}
 
-	booking, err := services.CreateBooking(user.ID, req.ExpertID, req.SlotID)
+	booking, err := services.CreateBooking(user.ID, req.ExpertID, services.SessionTime{
+		Date:      req.Date,
+		StartTime: req.StartTime,
+		TimeZone:  req.TimeZone,
+	}, req.OfferingID)
 	if err != nil {
-		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to create booking")
+		return respondBookingError(c, err, "failed to create booking")
 	}
 
 	return utils.RespondSuccess(c, http.StatusCreated, "booking created successfully", booking)
 }
+
+// GetBookingQuote itemizes the price of one seat of an expert's offering, or of the
+// expert's default session
+func GetBookingQuote(c echo.Context) error {
+	expertID, err := strconv.ParseUint(c.QueryParam("expertId"), 10, 32)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid expertId")
+	}
+
+	var offeringID uint64
+	if offeringIDStr := c.QueryParam("offeringId"); offeringIDStr != "" {
+		offeringID, err = strconv.ParseUint(offeringIDStr, 10, 32)
+		if err != nil {
+			return utils.RespondError(c, http.StatusBadRequest, err, "invalid offeringId")
+		}
+	}
+
+	quote, err := services.QuoteBooking(uint(expertID), uint(offeringID))
+	if err != nil {
+		return respondBookingError(c, err, "failed to quote booking")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "quote retrieved successfully", quote)
+}
+
+// CreateSlotHold reserves a time for the authenticated user while they check out
+func CreateSlotHold(c echo.Context) error {
+	var req CreateSlotHoldRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	hold, err := services.CreateSlotHold(user.ID, req.ExpertID, services.SessionTime{
+		Date:      req.Date,
+		StartTime: req.StartTime,
+		TimeZone:  req.TimeZone,
+	}, req.OfferingID)
+	if err != nil {
+		return respondBookingError(c, err, "failed to hold slot")
+	}
+
+	return utils.RespondSuccess(c, http.StatusCreated, "slot held successfully", hold)
+}
+
+// CreateBookingSeries books the expert at the same time every week or every other
+// week and reports which occurrences were booked
+func CreateBookingSeries(c echo.Context) error {
+	var req CreateBookingSeriesRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	result, err := services.CreateBookingSeries(user.ID, req.ExpertID, services.SessionTime{
+		Date:      req.Date,
+		StartTime: req.StartTime,
+		TimeZone:  req.TimeZone,
+	}, req.OfferingID, services.SeriesRule{
+		Frequency: models.SeriesFrequency(req.Frequency),
+		Count:     req.Count,
+		Until:     req.Until,
+	})
+	if err != nil {
+		var report *services.SeriesBookingError
+		if errors.As(err, &report) {
+			return utils.RespondError(c, http.StatusConflict, err, report.Occurrences)
+		}
+		return respondBookingError(c, err, "failed to create booking series")
+	}
+
+	return utils.RespondSuccess(c, http.StatusCreated, "booking series created successfully", result)
+}
+
+// ConfirmSlotHold converts the authenticated user's hold into a booking
+func ConfirmSlotHold(c echo.Context) error {
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid hold id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	booking, err := services.ConfirmSlotHold(id, user.ID)
+	if err != nil {
+		return respondBookingError(c, err, "failed to confirm slot hold")
+	}
+
+	return utils.RespondSuccess(c, http.StatusCreated, "booking created successfully", booking)
+}
+
+// ReleaseSlotHold gives up the authenticated user's hold
+func ReleaseSlotHold(c echo.Context) error {
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid hold id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	if err := services.ReleaseSlotHold(id, user.ID); err != nil {
+		return respondBookingError(c, err, "failed to release slot hold")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "slot hold released successfully", nil)
+}
+
+// GetMyBookings lists the bookings of the authenticated user
+func GetMyBookings(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	filter, err := parseBookingFilter(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid booking filter")
+	}
+
+	page, limit := parsePagination(c)
+
+	bookings, total, err := services.GetUserBookings(user.ID, filter, page, limit)
+	if err != nil {
+		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to get bookings")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "bookings retrieved successfully", bookingsPage(bookings, total, page, limit))
+}
+
+// GetExpertBookings lists the bookings made with the authenticated expert
+func GetExpertBookings(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	filter, err := parseBookingFilter(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid booking filter")
+	}
+
+	page, limit := parsePagination(c)
+
+	bookings, total, err := services.GetExpertBookings(expert.ID, filter, page, limit)
+	if err != nil {
+		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to get bookings")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "bookings retrieved successfully", bookingsPage(bookings, total, page, limit))
+}
+
+// CancelBooking cancels a booking on behalf of its user, its expert or an admin. With
+// scope "following" the later occurrences of its series are cancelled as well.
+func CancelBooking(c echo.Context) error {
+	var req CancelBookingRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid booking id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	if req.Scope == "following" {
+		bookings, err := services.CancelBookingAndFollowing(id, user, req.Reason)
+		if err != nil {
+			return respondBookingError(c, err, "failed to cancel bookings")
+		}
+		return utils.RespondSuccess(c, http.StatusOK, "bookings cancelled successfully", bookings)
+	}
+
+	booking, err := services.CancelBooking(id, user, req.Reason)
+	if err != nil {
+		return respondBookingError(c, err, "failed to update booking")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "booking cancelled successfully", booking)
+}
+
+// CompleteBooking marks a booking as completed
+func CompleteBooking(c echo.Context) error {
+	return transitionBooking(c, services.CompleteBooking, "booking completed successfully")
+}
+
+// MarkBookingNoShow marks a booking as a no-show
+func MarkBookingNoShow(c echo.Context) error {
+	return transitionBooking(c, services.MarkBookingNoShow, "booking marked as no-show")
+}
+
+// AcceptBooking lets the expert confirm a pending booking request
+func AcceptBooking(c echo.Context) error {
+	return transitionBooking(c, services.AcceptBooking, "booking accepted successfully")
+}
+
+// DeclineBooking lets the expert reject a pending booking request
+func DeclineBooking(c echo.Context) error {
+	return transitionBooking(c, services.DeclineBooking, "booking declined successfully")
+}
+
+// RescheduleBooking moves a booking to a new date and start time
+func RescheduleBooking(c echo.Context) error {
+	var req RescheduleBookingRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid booking id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	booking, err := services.RescheduleBooking(id, user, services.SessionTime{
+		Date:      req.Date,
+		StartTime: req.StartTime,
+		TimeZone:  req.TimeZone,
+	}, req.Reason)
+	if err != nil {
+		return respondBookingError(c, err, "failed to reschedule booking")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "booking rescheduled successfully", booking)
+}
+
+// GetBookingHistory returns the audit trail of a booking
+func GetBookingHistory(c echo.Context) error {
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid booking id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	transitions, err := services.GetBookingTransitions(id, user)
+	if err != nil {
+		return respondBookingError(c, err, "failed to get booking history")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "booking history retrieved successfully", transitions)
+}
+
+// transitionBooking handles the lifecycle endpoints that only take an optional reason
+func transitionBooking(c echo.Context, apply func(bookingID uint, actor *models.User, reason string) (*models.Booking, error), message string) error {
+	var req BookingTransitionRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid booking id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	booking, err := apply(id, user, req.Reason)
+	if err != nil {
+		return respondBookingError(c, err, "failed to update booking")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, message, booking)
+}
+
+// parseBookingFilter reads the status (comma separated), from and to ("YYYY-MM-DD",
+// both inclusive) query parameters
+func parseBookingFilter(c echo.Context) (services.BookingFilter, error) {
+	var filter services.BookingFilter
+
+	if status := c.QueryParam("status"); status != "" {
+		for _, s := range strings.Split(status, ",") {
+			bookingStatus := models.BookingStatus(strings.TrimSpace(s))
+			if !bookingStatus.IsValid() {
+				return filter, errors.New("unknown booking status: " + s)
+			}
+			filter.Statuses = append(filter.Statuses, bookingStatus)
+		}
+	}
+
+	if from := c.QueryParam("from"); from != "" {
+		parsed, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return filter, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		filter.From = parsed
+	}
+
+	if to := c.QueryParam("to"); to != "" {
+		parsed, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return filter, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		filter.To = parsed.AddDate(0, 0, 1)
+	}
+
+	return filter, nil
+}
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parsePagination reads the page and limit query parameters of booking lists. Pages
+// start at 1; the limit defaults to defaultPageLimit and is capped at maxPageLimit.
+func parsePagination(c echo.Context) (int, int) {
+	page, _ := strconv.Atoi(c.QueryParam("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	if limit < 1 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	return page, limit
+}
+
+func bookingsPage(bookings []models.Booking, total int64, page, limit int) map[string]interface{} {
+	return map[string]interface{}{
+		"bookings": bookings,
+		"meta": map[string]interface{}{
+			"current_page": page,
+			"total_pages":  int(math.Ceil(float64(total) / float64(limit))),
+			"total_items":  total,
+			"limit":        limit,
+		},
+	}
+}
+
+// bookingIDParam parses the :id path parameter of booking and hold routes
+func bookingIDParam(c echo.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+// respondBookingError maps booking service errors to HTTP statuses. Errors that are not
+// known to be caused by the request are reported as 500.
+func respondBookingError(c echo.Context, err error, details string) error {
+	var inputErr *services.InputError
+	switch {
+	case errors.As(err, &inputErr):
+		return utils.RespondError(c, http.StatusBadRequest, err, details)
+	case errors.Is(err, services.ErrBookingNotFound), errors.Is(err, services.ErrSlotHoldNotFound),
+		errors.Is(err, services.ErrWaitlistEntryNotFound), errors.Is(err, services.ErrPaymentNotFound):
+		return utils.RespondError(c, http.StatusNotFound, err, details)
+	case errors.Is(err, services.ErrBookingActionForbidden):
+		return utils.RespondError(c, http.StatusForbidden, err, details)
+	case errors.Is(err, services.ErrPaymentDeclined), errors.Is(err, services.ErrPaymentRequired):
+		return utils.RespondError(c, http.StatusPaymentRequired, err, details)
+	case errors.Is(err, services.ErrTimeSlotUnavailable), errors.Is(err, services.ErrInvalidBookingTransition),
+		errors.Is(err, services.ErrSlotHoldExpired), errors.Is(err, services.ErrSessionFull),
+		errors.Is(err, services.ErrAlreadyInSession), errors.Is(err, services.ErrPaymentNotCapturable),
+		errors.Is(err, services.ErrCheckoutExpired):
+		return utils.RespondError(c, http.StatusConflict, err, details)
+	default:
+		return utils.RespondError(c, http.StatusInternalServerError, err, details)
+	}
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type CancellationTierRequest struct {
+	MinNoticeMinutes *int `json:"min_notice_minutes" validate:"required,min=0"`
+	RefundPercent    *int `json:"refund_percent" validate:"required,min=0,max=100"`
+}
+
+// ReplaceCancellationPolicyRequest lists every tier of the policy, an empty list
+// refunds cancellations in full
+type ReplaceCancellationPolicyRequest struct {
+	Tiers []CancellationTierRequest `json:"tiers" validate:"max=10,dive"`
+}
+
+// ReplaceCancellationPolicy replaces the authenticated expert's cancellation policy
+func ReplaceCancellationPolicy(c echo.Context) error {
+	var req ReplaceCancellationPolicyRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return respondCancellationPolicyError(c, err, "expert profile not found")
+	}
+
+	tiers := make([]services.CancellationTier, len(req.Tiers))
+	for i, tier := range req.Tiers {
+		tiers[i] = services.CancellationTier{
+			MinNoticeMinutes: *tier.MinNoticeMinutes,
+			RefundPercent:    *tier.RefundPercent,
+		}
+	}
+
+	policy, err := services.ReplaceCancellationPolicy(expert.ID, tiers)
+	if err != nil {
+		return respondCancellationPolicyError(c, err, "failed to update cancellation policy")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "cancellation policy updated successfully", policy)
+}
+
+// GetCancellationPolicy returns an expert's cancellation policy, longest notice first
+func GetCancellationPolicy(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid expert id")
+	}
+
+	policy, err := services.GetCancellationPolicy(uint(id))
+	if err != nil {
+		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to get cancellation policy")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "cancellation policy retrieved successfully", policy)
+}
+
+// respondCancellationPolicyError reports invalid tiers as 400, a missing expert
+// profile as 404 and everything else as 500
+func respondCancellationPolicyError(c echo.Context, err error, details string) error {
+	var inputErr *services.InputError
+	switch {
+	case errors.As(err, &inputErr):
+		return utils.RespondError(c, http.StatusBadRequest, err, details)
+	case errors.Is(err, services.ErrExpertProfileNotFound):
+		return utils.RespondError(c, http.StatusNotFound, err, details)
+	default:
+		return utils.RespondError(c, http.StatusInternalServerError, err, details)
+	}
+}
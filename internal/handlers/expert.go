@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"math"
 	"net/http"
 	"strconv"
@@ -12,15 +13,27 @@ import (
 )
 
 type CreateExpertRequest struct {
-	Bio        string  `json:"bio" validate:"required"`
-	Expertise  string  `json:"expertise" validate:"required"`
-	HourlyRate float64 `json:"hourly_rate" validate:"required,gt=0"`
+	Bio             string `json:"bio" validate:"required"`
+	Expertise       string `json:"expertise" validate:"required"`
+	HourlyRateMinor int64  `json:"hourly_rate_minor" validate:"required,gt=0"` // In minor units of the currency, e.g. 4999 for 49.99 USD
+	Currency        string `json:"currency"`                                   // Optional ISO 4217 code, defaults to the configured currency
 }
 
 type UpdateExpertRequest struct {
-	Bio        string  `json:"bio"`
-	Expertise  string  `json:"expertise"`
-	HourlyRate float64 `json:"hourly_rate"`
+	Bio             string `json:"bio"`
+	Expertise       string `json:"expertise"`
+	HourlyRateMinor int64  `json:"hourly_rate_minor"` // In minor units of the currency, a new currency needs a new rate
+	Currency        string `json:"currency"`          // ISO 4217 code the expert charges in
+	BookingMode     string `json:"booking_mode"`      // "instant" or "manual"
+	// DefaultSessionMinutes is the session length used when no offering is chosen
+	DefaultSessionMinutes int `json:"default_session_minutes"`
+	// Scheduling rules, omitted fields are left unchanged
+	BufferBeforeMinutes *int `json:"buffer_before_minutes"`
+	BufferAfterMinutes  *int `json:"buffer_after_minutes"`
+	MinNoticeMinutes    *int `json:"min_notice_minutes"`
+	MaxAdvanceDays      *int `json:"max_advance_days"`
+	// TimeZone is the IANA name availability is written in, e.g. "Europe/Berlin"
+	TimeZone string `json:"time_zone"`
 }
 
 func CreateExpertProfile(c echo.Context) error {
@@ -34,8 +47,12 @@ func CreateExpertProfile(c echo.Context) error {
 		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
 	}
 
-	expert, err := services.CreateExpertProfile(user.ID, req.Bio, req.Expertise, req.HourlyRate)
+	expert, err := services.CreateExpertProfile(user.ID, req.Bio, req.Expertise, req.HourlyRateMinor, req.Currency)
 	if err != nil {
+		var inputErr *services.InputError
+		if errors.As(err, &inputErr) {
+			return utils.RespondError(c, http.StatusBadRequest, err, "failed to create expert profile")
+		}
 		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to create expert profile")
 	}
 
@@ -75,7 +92,7 @@ func calculateProfileCompletion(expert *models.Expert) int {
 	if expert.Expertise != "" {
 		completedFields++
 	}
-	if expert.HourlyRate > 0 {
+	if expert.HourlyRate.IsPositive() {
 		completedFields++
 	}
 
@@ -93,8 +110,27 @@ func UpdateExpertProfile(c echo.Context) error {
 		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
 	}
 
-	expert, err := services.UpdateExpertProfile(user.ID, req.Bio, req.Expertise, req.HourlyRate)
+	expert, err := services.UpdateExpertProfile(user.ID, services.ExpertProfileUpdate{
+		Bio:                   req.Bio,
+		Expertise:             req.Expertise,
+		HourlyRateMinor:       req.HourlyRateMinor,
+		Currency:              req.Currency,
+		BookingMode:           models.BookingMode(req.BookingMode),
+		DefaultSessionMinutes: req.DefaultSessionMinutes,
+		BufferBeforeMinutes:   req.BufferBeforeMinutes,
+		BufferAfterMinutes:    req.BufferAfterMinutes,
+		MinNoticeMinutes:      req.MinNoticeMinutes,
+		MaxAdvanceDays:        req.MaxAdvanceDays,
+		TimeZone:              req.TimeZone,
+	})
 	if err != nil {
+		var inputErr *services.InputError
+		switch {
+		case errors.As(err, &inputErr):
+			return utils.RespondError(c, http.StatusBadRequest, err, "failed to update expert profile")
+		case errors.Is(err, services.ErrExpertProfileNotFound):
+			return utils.RespondError(c, http.StatusNotFound, err, "failed to update expert profile")
+		}
 		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to update expert profile")
 	}
 
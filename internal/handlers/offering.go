@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type CreateOfferingRequest struct {
+	Title           string `json:"title" validate:"required"`
+	DurationMinutes int    `json:"duration_minutes" validate:"required,min=5,max=480"`
+	Capacity        int    `json:"capacity" validate:"omitempty,min=1,max=500"` // Optional, more than 1 for group sessions
+	// Optional fixed price per seat in minor units of the expert's currency, replacing the hourly rate
+	PriceMinor      *int64 `json:"price_minor" validate:"omitempty,gte=0"`
+	DiscountPercent *int   `json:"discount_percent" validate:"omitempty,min=0,max=100"`
+}
+
+type UpdateOfferingRequest struct {
+	Title           string `json:"title"`
+	DurationMinutes int    `json:"duration_minutes" validate:"omitempty,min=5,max=480"`
+	Capacity        int    `json:"capacity" validate:"omitempty,min=1,max=500"`
+	// PriceMinor 0 goes back to charging the hourly rate, omitted fields are left unchanged
+	PriceMinor      *int64 `json:"price_minor" validate:"omitempty,gte=0"`
+	DiscountPercent *int   `json:"discount_percent" validate:"omitempty,min=0,max=100"`
+}
+
+// CreateOffering adds a session type to the authenticated expert's profile
+func CreateOffering(c echo.Context) error {
+	var req CreateOfferingRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	offering, err := services.CreateOffering(expert.ID, req.Title, req.DurationMinutes, req.Capacity, services.OfferingPricing{
+		PriceMinor:      req.PriceMinor,
+		DiscountPercent: req.DiscountPercent,
+	})
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "failed to create offering")
+	}
+
+	return utils.RespondSuccess(c, http.StatusCreated, "offering created successfully", offering)
+}
+
+// GetOfferings retrieves the authenticated expert's offerings
+func GetOfferings(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	offerings, err := services.GetOfferingsByExpertID(expert.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to get offerings")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "offerings retrieved successfully", offerings)
+}
+
+// UpdateOffering updates one of the authenticated expert's offerings
+func UpdateOffering(c echo.Context) error {
+	var req UpdateOfferingRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid offering id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	offering, err := services.UpdateOffering(uint(id), expert.ID, req.Title, req.DurationMinutes, req.Capacity, services.OfferingPricing{
+		PriceMinor:      req.PriceMinor,
+		DiscountPercent: req.DiscountPercent,
+	})
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "failed to update offering")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "offering updated successfully", offering)
+}
+
+// DeleteOffering withdraws one of the authenticated expert's offerings
+func DeleteOffering(c echo.Context) error {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid offering id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	expert, err := services.GetExpertProfile(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "expert profile not found")
+	}
+
+	if err := services.DeleteOffering(uint(id), expert.ID); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "failed to delete offering")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "offering deleted successfully", nil)
+}
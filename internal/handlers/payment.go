@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/payments"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+// CheckoutRequest pays for either an existing slot hold or a new session
+type CheckoutRequest struct {
+	HoldID     uint   `json:"hold_id"` // Optional, the session fields are ignored when set
+	ExpertID   uint   `json:"expert_id" validate:"required_without=HoldID"`
+	OfferingID uint   `json:"offering_id"`                                                   // Optional, defaults to the expert's default session
+	Date       string `json:"date" validate:"required_without=HoldID"`                       // Format: "YYYY-MM-DD"
+	StartTime  string `json:"start_time" validate:"required_without=HoldID,omitempty,clock"` // Format: "HH:MM"
+	TimeZone   string `json:"time_zone"`                                                     // Optional IANA name, defaults to the expert's time zone
+}
+
+// Checkout books a session for the authenticated user pending its payment and
+// returns the client secret to complete the payment with the provider
+func Checkout(c echo.Context) error {
+	var req CheckoutRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	var (
+		result *services.CheckoutResult
+		err    error
+	)
+	if req.HoldID != 0 {
+		result, err = services.CheckoutSlotHold(req.HoldID, user.ID)
+	} else {
+		result, err = services.Checkout(user.ID, req.ExpertID, services.SessionTime{
+			Date:      req.Date,
+			StartTime: req.StartTime,
+			TimeZone:  req.TimeZone,
+		}, req.OfferingID)
+	}
+	if err != nil {
+		return respondBookingError(c, err, "failed to check out")
+	}
+
+	return utils.RespondSuccess(c, http.StatusCreated, "checkout started successfully", result)
+}
+
+// GetPayment returns one of the authenticated user's payments and its booking
+func GetPayment(c echo.Context) error {
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid payment id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	result, err := services.GetPayment(id, user.ID)
+	if err != nil {
+		return respondBookingError(c, err, "failed to get payment")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "payment retrieved successfully", result)
+}
+
+// CapturePayment collects one of the authenticated user's payments and confirms
+// its booking
+func CapturePayment(c echo.Context) error {
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid payment id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	result, err := services.CapturePayment(id, user.ID)
+	if err != nil {
+		return respondBookingError(c, err, "failed to capture payment")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "payment captured successfully", result)
+}
+
+// maxWebhookBytes bounds the webhook payloads read into memory
+const maxWebhookBytes = 1 << 20
+
+// PaymentWebhook receives payment status changes from the provider named in the path.
+// The provider verifies the signature before the event is applied.
+func PaymentWebhook(c echo.Context) error {
+	provider, err := payments.Get(c.Param("provider"))
+	if err != nil {
+		return utils.RespondError(c, http.StatusNotFound, err, "unknown payment provider")
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWebhookBytes))
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "failed to read webhook body")
+	}
+
+	event, err := provider.ParseWebhook(payload, c.Request().Header)
+	if err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) || errors.Is(err, payments.ErrStaleWebhook) {
+			return utils.RespondError(c, http.StatusUnauthorized, err, "webhook rejected")
+		}
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid webhook")
+	}
+
+	if err := services.HandlePaymentEvent(provider.Name(), event); err != nil {
+		if errors.Is(err, services.ErrPaymentNotFound) {
+			return utils.RespondError(c, http.StatusNotFound, err, "payment not found")
+		}
+		if errors.Is(err, services.ErrPaymentEventMismatch) {
+			return utils.RespondError(c, http.StatusBadRequest, err, "webhook rejected")
+		}
+		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to process webhook")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "webhook processed successfully", nil)
+}
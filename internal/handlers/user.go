@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type UpdateUserRequest struct {
+	Name     string `json:"name"`
+	TimeZone string `json:"time_zone"` // IANA name, e.g. "America/New_York"
+}
+
+// GetMe returns the authenticated user
+func GetMe(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "user retrieved successfully", user)
+}
+
+// UpdateMe updates the authenticated user's profile
+func UpdateMe(c echo.Context) error {
+	var req UpdateUserRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	updated, err := services.UpdateUserProfile(user.ID, req.Name, req.TimeZone)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "failed to update user")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "user updated successfully", updated)
+}
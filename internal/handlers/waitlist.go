@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/services"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
+	"github.com/labstack/echo/v4"
+)
+
+type JoinWaitlistRequest struct {
+	ExpertID   uint   `json:"expert_id" validate:"required"`
+	OfferingID uint   `json:"offering_id"`                          // Optional, defaults to the expert's default session
+	Date       string `json:"date" validate:"required"`             // Format: "YYYY-MM-DD"
+	StartTime  string `json:"start_time" validate:"required,clock"` // Format: "HH:MM"
+	TimeZone   string `json:"time_zone"`                            // Optional IANA name, defaults to the expert's time zone
+}
+
+// JoinWaitlist queues the authenticated user for a taken session. When the time frees
+// up the user is offered a slot hold to confirm before the offer expires.
+func JoinWaitlist(c echo.Context) error {
+	var req JoinWaitlistRequest
+	if err := c.Bind(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid request body")
+	}
+
+	if err := c.Validate(&req); err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "validation failed")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	entry, err := services.JoinWaitlist(user.ID, req.ExpertID, services.SessionTime{
+		Date:      req.Date,
+		StartTime: req.StartTime,
+		TimeZone:  req.TimeZone,
+	}, req.OfferingID)
+	if err != nil {
+		return respondBookingError(c, err, "failed to join waitlist")
+	}
+
+	return utils.RespondSuccess(c, http.StatusCreated, "joined waitlist successfully", entry)
+}
+
+// GetMyWaitlist lists the authenticated user's waiting and offered entries
+func GetMyWaitlist(c echo.Context) error {
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	entries, err := services.GetUserWaitlist(user.ID)
+	if err != nil {
+		return utils.RespondError(c, http.StatusInternalServerError, err, "failed to get waitlist")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "waitlist retrieved successfully", entries)
+}
+
+// LeaveWaitlist withdraws one of the authenticated user's entries, declining any
+// outstanding offer
+func LeaveWaitlist(c echo.Context) error {
+	id, err := bookingIDParam(c)
+	if err != nil {
+		return utils.RespondError(c, http.StatusBadRequest, err, "invalid waitlist entry id")
+	}
+
+	user, ok := c.Get("user").(*models.User)
+	if !ok {
+		return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+	}
+
+	if err := services.LeaveWaitlist(id, user.ID); err != nil {
+		return respondBookingError(c, err, "failed to leave waitlist")
+	}
+
+	return utils.RespondSuccess(c, http.StatusOK, "left waitlist successfully", nil)
+}
@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm/clause"
+)
+
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// Idempotency replays the stored response of a mutating request when it is retried
+// with the same Idempotency-Key header. Reusing a key with a different request is
+// rejected with 422. It must run after AuthMiddleware since keys are scoped per user.
+func Idempotency(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(HeaderIdempotencyKey)
+		if key == "" || !isMutatingMethod(c.Request().Method) {
+			return next(c)
+		}
+
+		if len(key) > 255 {
+			return utils.RespondError(c, http.StatusBadRequest, nil, "idempotency key must be at most 255 characters")
+		}
+
+		user, ok := c.Get("user").(*models.User)
+		if !ok {
+			return utils.RespondError(c, http.StatusUnauthorized, nil, "unauthorized")
+		}
+
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return utils.RespondError(c, http.StatusBadRequest, err, "failed to read request body")
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		db := database.GetDB()
+		record := models.IdempotencyKey{
+			UserID:      user.ID,
+			Key:         key,
+			Method:      c.Request().Method,
+			Path:        c.Request().URL.Path,
+			RequestHash: hashRequest(c.Request().Method, c.Request().URL.Path, body),
+		}
+
+		// Claim the key; the unique index makes concurrent retries lose the race
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+		if result.Error != nil {
+			return utils.RespondError(c, http.StatusInternalServerError, result.Error, "failed to store idempotency key")
+		}
+
+		if result.RowsAffected == 0 {
+			var existing models.IdempotencyKey
+			if err := db.Where("user_id = ? AND key = ?", user.ID, key).First(&existing).Error; err != nil {
+				return utils.RespondError(c, http.StatusInternalServerError, err, "failed to load idempotency key")
+			}
+			return replay(c, &existing, record.RequestHash)
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = recorder
+
+		handlerErr := next(c)
+
+		// Failed requests may be retried with the same key
+		status := c.Response().Status
+		if handlerErr != nil || !c.Response().Committed || status >= http.StatusInternalServerError {
+			releaseKey(&record)
+			return handlerErr
+		}
+
+		err = db.Model(&record).Updates(map[string]interface{}{
+			"status_code":   status,
+			"content_type":  c.Response().Header().Get(echo.HeaderContentType),
+			"response_body": recorder.body.Bytes(),
+		}).Error
+		if err != nil {
+			// Retries would otherwise see the request as still in progress forever
+			log.Printf("Failed to store response for idempotency key %d: %v", record.ID, err)
+			releaseKey(&record)
+		}
+
+		return nil
+	}
+}
+
+// releaseKey deletes a claimed key so that the request can be retried with it
+func releaseKey(record *models.IdempotencyKey) {
+	if err := database.GetDB().Delete(record).Error; err != nil {
+		log.Printf("Failed to release idempotency key %d: %v", record.ID, err)
+	}
+}
+
+func replay(c echo.Context, existing *models.IdempotencyKey, requestHash string) error {
+	if existing.RequestHash != requestHash {
+		return utils.RespondError(c, http.StatusUnprocessableEntity, errors.New("idempotency key reused with a different request"), "idempotency key mismatch")
+	}
+
+	if existing.StatusCode == 0 {
+		return utils.RespondError(c, http.StatusConflict, errors.New("a request with this idempotency key is still in progress"), "request in progress")
+	}
+
+	c.Response().Header().Set("Idempotent-Replayed", "true")
+	return c.Blob(existing.StatusCode, existing.ContentType, existing.ResponseBody)
+}
+
+func hashRequest(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// responseRecorder copies everything written to the client so it can be replayed
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+type OverrideKind string
+
+const (
+	// OverrideBlackout makes whole days unavailable, e.g. a vacation
+	OverrideBlackout OverrideKind = "blackout"
+	// OverrideBlock makes part of a single day unavailable
+	OverrideBlock OverrideKind = "block"
+	// OverrideExtra adds a one-off availability window on a single day
+	OverrideExtra OverrideKind = "extra"
+)
+
+// AvailabilityOverride is a date-specific exception to an expert's weekly availability.
+// Dates and times are read in the expert's time zone.
+type AvailabilityOverride struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	ExpertID  uint         `gorm:"not null;index:idx_availability_overrides_expert_date,priority:1" json:"expert_id"`
+	Kind      OverrideKind `gorm:"type:varchar(20);not null" json:"kind"`
+	Date      string       `gorm:"type:varchar(10);not null;index:idx_availability_overrides_expert_date,priority:2" json:"date"` // Format: "YYYY-MM-DD"
+	EndDate   string       `gorm:"type:varchar(10);not null" json:"end_date"`                                                     // Inclusive, equals Date except for multi-day blackouts
+	StartTime string       `json:"start_time,omitempty"`                                                                          // Format: "HH:MM", empty for blackouts
+	EndTime   string       `json:"end_time,omitempty"`                                                                            // Format: "HH:MM", empty for blackouts
+	Reason    string       `json:"reason"`
+	Expert    Expert       `gorm:"foreignKey:ExpertID" json:"-"`
+	CreatedAt time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -5,21 +5,49 @@ import "time"
 type BookingStatus string
 
 const (
-	BookingStatusPending BookingStatus = "pending"
+	BookingStatusPending   BookingStatus = "pending"
 	BookingStatusConfirmed BookingStatus = "confirmed"
 	BookingStatusCancelled BookingStatus = "cancelled"
 	BookingStatusCompleted BookingStatus = "completed"
+	BookingStatusNoShow    BookingStatus = "no_show"
+	BookingStatusDeclined  BookingStatus = "declined"
+	BookingStatusExpired   BookingStatus = "expired"
+	// BookingStatusAwaitingPayment bookings hold their time during checkout until
+	// their payment completes
+	BookingStatusAwaitingPayment BookingStatus = "awaiting_payment"
 )
 
+// IsValid reports whether the status is one of the known booking statuses
+func (s BookingStatus) IsValid() bool {
+	switch s {
+	case BookingStatusPending, BookingStatusConfirmed, BookingStatusCancelled, BookingStatusCompleted, BookingStatusNoShow,
+		BookingStatusDeclined, BookingStatusExpired, BookingStatusAwaitingPayment:
+		return true
+	}
+	return false
+}
+
+// ReleasedBookingStatuses are the statuses of bookings that no longer occupy their
+// time range. Every other booking blocks the expert's time; the database enforces
+// this with the bookings_no_overlap exclusion constraint.
+var ReleasedBookingStatuses = []BookingStatus{BookingStatusCancelled, BookingStatusDeclined, BookingStatusExpired}
+
 type Booking struct {
-	ID        uint      `gorm:"primaryKey"`
-	UserID    uint      `gorm:"uniqueIndex"`
-	ExpertID  uint      `gorm:"uniqueIndex"`
-	SlotID    uint      `gorm:"uniqueIndex"`
-	Status    BookingStatus
-	User      User      `gorm:"foreignKey:UserID"`
-	Expert    Expert    `gorm:"foreignKey:ExpertID"`
-	Slot      AvailabilitySlot `gorm:"foreignKey:SlotID"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime"`
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"index:idx_bookings_user_start,priority:1"`
+	ExpertID   uint      `gorm:"index:idx_bookings_expert_start,priority:1"`
+	SlotID     *uint     // Availability window the booking falls in
+	OfferingID *uint     // Offering that set the session length, nil for the expert's default
+	SeriesID   *uint     `gorm:"index"`                                                                               // Recurring series the booking belongs to, nil for one-off bookings
+	StartAt    time.Time `gorm:"index:idx_bookings_user_start,priority:2;index:idx_bookings_expert_start,priority:2"` // Concrete start of the appointment
+	EndAt      time.Time // Concrete end of the appointment
+	Status     BookingStatus
+	ExpiresAt  *time.Time        // Pending and unpaid bookings expire at this time unless the expert acts or the payment completes
+	SessionKey *string           `gorm:"type:varchar(64);index"` // Shared by the seats of a group session, nil for individual sessions
+	Quote      *PriceQuote       `gorm:"type:jsonb"`             // Price when the booking was made, nil for bookings made before pricing
+	User       User              `gorm:"foreignKey:UserID"`
+	Expert     Expert            `gorm:"foreignKey:ExpertID"`
+	Slot       *AvailabilitySlot `gorm:"foreignKey:SlotID;constraint:OnDelete:SET NULL"`
+	CreatedAt  time.Time         `gorm:"autoCreateTime"`
+	UpdatedAt  time.Time         `gorm:"autoUpdateTime"`
 }
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+type SeriesFrequency string
+
+const (
+	SeriesFrequencyWeekly   SeriesFrequency = "weekly"
+	SeriesFrequencyBiweekly SeriesFrequency = "biweekly"
+)
+
+// BookingSeries is a recurring booking with the same expert at the same local time.
+// Each occurrence is an ordinary booking that refers back to the series.
+type BookingSeries struct {
+	ID         uint            `gorm:"primaryKey" json:"id"`
+	UserID     uint            `gorm:"not null;index" json:"user_id"`
+	ExpertID   uint            `gorm:"not null;index" json:"expert_id"`
+	OfferingID *uint           `json:"offering_id,omitempty"`
+	Frequency  SeriesFrequency `gorm:"type:varchar(20);not null" json:"frequency"`
+	FirstDate  string          `gorm:"type:varchar(10);not null" json:"first_date"`  // Format: "YYYY-MM-DD"
+	StartTime  string          `gorm:"not null" json:"start_time"`                   // Format: "HH:MM"
+	TimeZone   string          `gorm:"type:varchar(64);not null" json:"time_zone"`   // IANA name the dates and time are in
+	Count      int             `json:"count,omitempty"`                              // Number of occurrences, 0 when bounded by UntilDate
+	UntilDate  string          `gorm:"type:varchar(10)" json:"until_date,omitempty"` // Last possible date, inclusive
+	User       User            `gorm:"foreignKey:UserID" json:"-"`
+	Expert     Expert          `gorm:"foreignKey:ExpertID" json:"-"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type ActorRole string
+
+const (
+	ActorUser   ActorRole = "user"
+	ActorExpert ActorRole = "expert"
+	ActorAdmin  ActorRole = "admin"
+	ActorSystem ActorRole = "system"
+)
+
+// BookingTransition is an audit record of a single change to a booking
+type BookingTransition struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	BookingID   uint          `gorm:"index;not null" json:"booking_id"`
+	Action      string        `gorm:"type:varchar(20)" json:"action"`
+	FromStatus  BookingStatus `gorm:"type:varchar(20)" json:"from_status"`
+	ToStatus    BookingStatus `gorm:"type:varchar(20)" json:"to_status"`
+	FromStartAt *time.Time    `json:"from_start_at,omitempty"` // Set when the booking was rescheduled
+	ToStartAt   *time.Time    `json:"to_start_at,omitempty"`   // Set when the booking was rescheduled
+	ActorID     *uint         `json:"actor_id"`                // Nil for system transitions
+	ActorRole   ActorRole     `gorm:"type:varchar(20)" json:"actor_role"`
+	Reason      string        `json:"reason"`
+	CreatedAt   time.Time     `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// CancellationPolicyTier grants users who cancel at least MinNoticeMinutes before the
+// session a refund of RefundPercent of what they paid. An expert's tiers together form
+// their cancellation policy.
+type CancellationPolicyTier struct {
+	ID               uint      `gorm:"primaryKey" json:"id"`
+	ExpertID         uint      `gorm:"not null;index" json:"expert_id"`
+	MinNoticeMinutes int       `gorm:"not null" json:"min_notice_minutes"`
+	RefundPercent    int       `gorm:"not null" json:"refund_percent"`
+	Expert           Expert    `gorm:"foreignKey:ExpertID" json:"-"`
+	CreatedAt        time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -2,14 +2,31 @@ package models
 
 import "time"
 
+type BookingMode string
+
+const (
+	// BookingModeInstant confirms bookings as soon as they are made
+	BookingModeInstant BookingMode = "instant"
+	// BookingModeManual creates bookings as pending until the expert accepts them
+	BookingModeManual BookingMode = "manual"
+)
+
 type Expert struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	UserID     uint      `gorm:"uniqueIndex" json:"user_id"`
-	Bio        string    `json:"bio"`
-	Expertise  string    `json:"expertise"`
-	HourlyRate float64   `json:"hourly_rate"`
-	IsVerified bool      `gorm:"default:false" json:"is_verified"`
-	User       User      `gorm:"foreignKey:UserID" json:"user"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID                    uint        `gorm:"primaryKey" json:"id"`
+	UserID                uint        `gorm:"uniqueIndex" json:"user_id"`
+	Bio                   string      `json:"bio"`
+	Expertise             string      `json:"expertise"`
+	HourlyRate            Money       `gorm:"embedded;embeddedPrefix:hourly_rate_" json:"hourly_rate"` // Also sets the currency the expert charges in
+	IsVerified            bool        `gorm:"default:false" json:"is_verified"`
+	BookingMode           BookingMode `gorm:"type:varchar(20);default:instant" json:"booking_mode"`
+	DefaultSessionMinutes int         `gorm:"default:30" json:"default_session_minutes"`
+	BufferBeforeMinutes   int         `gorm:"default:0" json:"buffer_before_minutes"`        // Padding before each session
+	BufferAfterMinutes    int         `gorm:"default:0" json:"buffer_after_minutes"`         // Padding after each session
+	MinNoticeMinutes      int         `gorm:"default:0" json:"min_notice_minutes"`           // No bookings starting sooner than this
+	MaxAdvanceDays        int         `gorm:"default:0" json:"max_advance_days"`             // No bookings further ahead, 0 for no limit
+	TimeZone              string      `gorm:"type:varchar(64);default:UTC" json:"time_zone"` // IANA name, availability is read in it
+	User                  User        `gorm:"foreignKey:UserID" json:"user"`
+	Offerings             []Offering  `gorm:"foreignKey:ExpertID" json:"offerings,omitempty"`
+	CreatedAt             time.Time   `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt             time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the outcome of a mutating request so that retries carrying
+// the same Idempotency-Key header replay it instead of running it again
+type IdempotencyKey struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"not null;uniqueIndex:idx_idempotency_keys_user_key,priority:1"`
+	Key          string `gorm:"not null;size:255;uniqueIndex:idx_idempotency_keys_user_key,priority:2"`
+	Method       string `gorm:"type:varchar(10)"`
+	Path         string
+	RequestHash  string `gorm:"type:char(64);not null"` // SHA-256 of method, path and body
+	StatusCode   int    // 0 while the original request is still in flight
+	ContentType  string
+	ResponseBody []byte
+	CreatedAt    time.Time `gorm:"autoCreateTime;index"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Money is an exact amount in the minor units of a currency, e.g. cents. Embed it with
+// a column prefix: `gorm:"embedded;embeddedPrefix:price_"`.
+type Money struct {
+	Minor    int64  `gorm:"not null;default:0" json:"minor"`                   // Amount in minor units
+	Currency string `gorm:"type:char(3);not null;default:USD" json:"currency"` // ISO 4217 code, upper case
+}
+
+// currencyExponents lists the currencies whose minor unit isn't a hundredth of the
+// major unit. Every other currency has two decimals.
+var currencyExponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0, "PYG": 0,
+	"RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// CurrencyExponent returns the number of decimals of the currency's minor unit
+func CurrencyExponent(currency string) int {
+	if exponent, ok := currencyExponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// NormalizeCurrency upper-cases an ISO 4217 code and checks that it is three letters
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", errors.New("currency must be a three letter ISO 4217 code")
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", errors.New("currency must be a three letter ISO 4217 code")
+		}
+	}
+	return code, nil
+}
+
+// MoneyFromMajor converts an amount in major units, such as 49.99 dollars, rounding
+// to the nearest minor unit
+func MoneyFromMajor(amount float64, currency string) Money {
+	scale := math.Pow10(CurrencyExponent(currency))
+	return Money{Minor: int64(math.Round(amount * scale)), Currency: currency}
+}
+
+// Major returns the amount in major units, for display only
+func (m Money) Major() float64 {
+	return float64(m.Minor) / math.Pow10(CurrencyExponent(m.Currency))
+}
+
+// IsPositive reports whether the amount is more than zero
+func (m Money) IsPositive() bool {
+	return m.Minor > 0
+}
+
+// Scale returns the amount multiplied by numerator/denominator, rounding half away
+// from zero to the nearest minor unit
+func (m Money) Scale(numerator, denominator int64) Money {
+	product := m.Minor * numerator
+	half := denominator / 2
+	if product < 0 {
+		half = -half
+	}
+	return Money{Minor: (product + half) / denominator, Currency: m.Currency}
+}
+
+// String formats the amount as e.g. "49.99 USD"
+func (m Money) String() string {
+	exponent := CurrencyExponent(m.Currency)
+	return fmt.Sprintf("%.*f %s", exponent, m.Major(), m.Currency)
+}
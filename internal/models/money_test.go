@@ -0,0 +1,51 @@
+package models
+
+import "testing"
+
+func TestMoneyFromMajor(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     int64
+	}{
+		{49.99, "USD", 4999},
+		{19.99, "USD", 1999},
+		{19.999, "USD", 2000},
+		{0.005, "EUR", 1},
+		{1500, "JPY", 1500},
+		{1500.4, "JPY", 1500},
+		{1.234, "KWD", 1234},
+		{0, "USD", 0},
+	}
+
+	for _, tt := range tests {
+		got := MoneyFromMajor(tt.amount, tt.currency)
+		if got.Minor != tt.want || got.Currency != tt.currency {
+			t.Errorf("MoneyFromMajor(%v, %s) = %d %s, want %d %s", tt.amount, tt.currency, got.Minor, got.Currency, tt.want, tt.currency)
+		}
+	}
+}
+
+func TestMoneyScale(t *testing.T) {
+	tests := []struct {
+		minor                  int64
+		numerator, denominator int64
+		want                   int64
+	}{
+		{1999, 3, 4, 1499},
+		{1999, 1, 2, 1000},
+		{10, 1, 4, 3},
+		{9, 1, 4, 2},
+		{-10, 1, 4, -3},
+		{-1999, 3, 4, -1499},
+		{5000, 100, 100, 5000},
+		{5000, 0, 100, 0},
+	}
+
+	for _, tt := range tests {
+		got := Money{Minor: tt.minor, Currency: "USD"}.Scale(tt.numerator, tt.denominator)
+		if got.Minor != tt.want || got.Currency != "USD" {
+			t.Errorf("%d.Scale(%d, %d) = %d %s, want %d USD", tt.minor, tt.numerator, tt.denominator, got.Minor, got.Currency, tt.want)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Offering is a kind of session an expert sells, such as a 60-minute consultation
+type Offering struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	ExpertID        uint           `gorm:"not null;index" json:"expert_id"`
+	Title           string         `gorm:"not null" json:"title"`
+	DurationMinutes int            `gorm:"not null" json:"duration_minutes"`
+	Capacity        int            `gorm:"not null;default:1" json:"capacity"` // Seats per session, more than 1 for group sessions
+	PriceMinor      *int64         `json:"price_minor"`                        // Fixed price per seat in minor units of the expert's currency, nil to charge the hourly rate
+	DiscountPercent int            `gorm:"not null;default:0" json:"discount_percent"`
+	Expert          Expert         `gorm:"foreignKey:ExpertID" json:"-"`
+	CreatedAt       time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"` // Kept for bookings that reference it
+}
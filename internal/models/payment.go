@@ -9,13 +9,20 @@ const (
 	PaymentCompleted PaymentStatus = "completed"
 	PaymentFailed    PaymentStatus = "failed"
 	PaymentRefunded  PaymentStatus = "refunded"
+	// PaymentPartiallyRefunded payments had part of their amount returned
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded"
 )
 
 type Payment struct {
-	ID        uint `gorm:"primaryKey"`
-	BookingID uint
-	Amount    float64
-	Status    PaymentStatus `gorm:"type:varchar(20)"`
-	Provider  string
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ID               uint          `gorm:"primaryKey" json:"id"`
+	BookingID        uint          `gorm:"index" json:"booking_id"`
+	UserID           uint          `gorm:"index" json:"user_id"` // Payer
+	Amount           Money         `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	Quote            *PriceQuote   `gorm:"type:jsonb" json:"quote,omitempty"`        // The quote Amount was charged for
+	RefundedMinor    int64         `gorm:"not null;default:0" json:"refunded_minor"` // Returned so far, in minor units of Amount's currency
+	Status           PaymentStatus `gorm:"type:varchar(20)" json:"status"`
+	Provider         string        `json:"provider"`
+	ProviderIntentID string        `gorm:"index" json:"provider_intent_id"` // The provider's ID of the payment, empty until it was started
+	CreatedAt        time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PaymentEvent records a processed provider webhook event so that redeliveries of the
+// same event are ignored
+type PaymentEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	Provider  string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_payment_events_provider_event,priority:1"`
+	EventID   string    `gorm:"size:255;not null;uniqueIndex:idx_payment_events_provider_event,priority:2"`
+	Type      string    `gorm:"type:varchar(50)"`
+	PaymentID *uint     `gorm:"index"` // Nil for events about payments we don't know
+	CreatedAt time.Time `gorm:"autoCreateTime"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+type RefundStatus string
+
+const (
+	// RefundPending refunds are owed to the payer and wait to be sent to the provider
+	RefundPending   RefundStatus = "pending"
+	RefundSucceeded RefundStatus = "succeeded"
+	// RefundFailed refunds were not made by the provider and are sent again
+	RefundFailed RefundStatus = "failed"
+)
+
+// PaymentRefund records money returned, or owed, to the payer of a payment and why
+type PaymentRefund struct {
+	ID               uint         `gorm:"primaryKey" json:"id"`
+	PaymentID        uint         `gorm:"not null;index" json:"payment_id"`
+	Amount           Money        `gorm:"embedded;embeddedPrefix:amount_" json:"amount"`
+	Status           RefundStatus `gorm:"type:varchar(20);not null;default:succeeded;index" json:"status"`
+	ProviderRefundID string       `gorm:"index" json:"provider_refund_id"` // The provider's ID of the refund, empty until it was made
+	Reason           string       `json:"reason"`
+	Attempts         int          `gorm:"not null;default:0" json:"-"` // Failed attempts to send it to the provider
+	LastError        string       `json:"-"`
+	CreatedAt        time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+type QuoteLineKind string
+
+const (
+	QuoteLineSession  QuoteLineKind = "session"
+	QuoteLineDiscount QuoteLineKind = "discount"
+	QuoteLineTax      QuoteLineKind = "tax"
+)
+
+// QuoteLine is one item of a price quote. Discounts have negative amounts.
+type QuoteLine struct {
+	Kind        QuoteLineKind `json:"kind"`
+	Description string        `json:"description"`
+	Amount      Money         `json:"amount"`
+}
+
+// PriceQuote itemizes what a session costs. Bookings and payments keep the quote they
+// were made with, so later changes to rates or taxes don't alter them.
+type PriceQuote struct {
+	Lines    []QuoteLine `json:"lines"`
+	Subtotal Money       `json:"subtotal"` // After discounts, before tax
+	Tax      Money       `json:"tax"`
+	Total    Money       `json:"total"`
+	QuotedAt time.Time   `json:"quoted_at"`
+}
+
+// Value stores the quote as JSON
+func (q PriceQuote) Value() (driver.Value, error) {
+	return json.Marshal(q)
+}
+
+// Scan reads a quote stored as JSON
+func (q *PriceQuote) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, q)
+	case string:
+		return json.Unmarshal([]byte(v), q)
+	default:
+		return errors.New("unsupported price quote value")
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// SlotHold temporarily reserves an expert's time range for a user while they check out
+type SlotHold struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ExpertID   uint      `gorm:"not null;index:idx_slot_holds_expert_start,priority:1" json:"expert_id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	StartAt    time.Time `gorm:"not null;index:idx_slot_holds_expert_start,priority:2" json:"start_at"`
+	EndAt      time.Time `gorm:"not null" json:"end_at"`
+	ExpiresAt  time.Time `gorm:"not null;index" json:"expires_at"`
+	OfferingID *uint     `json:"offering_id,omitempty"`
+	BookingID  *uint     `json:"booking_id,omitempty"`            // Set once the hold is converted into a booking
+	SessionKey *string   `gorm:"type:varchar(64);index" json:"-"` // Group session the hold reserves a seat in
+	Expert     Expert    `gorm:"foreignKey:ExpertID" json:"-"`
+	User       User      `gorm:"foreignKey:UserID" json:"-"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -16,6 +16,7 @@ type User struct {
 	Email     string    `gorm:"uniqueIndex" json:"email"`
 	Password  string    `json:"-"` // Don't return password
 	Role      UserRole  `gorm:"type:varchar(20)" json:"role"`
+	TimeZone  string    `gorm:"type:varchar(64);default:UTC" json:"time_zone"` // IANA name, e.g. "America/New_York"
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
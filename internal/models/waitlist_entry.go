@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type WaitlistStatus string
+
+const (
+	// WaitlistStatusWaiting entries wait for the time to become free
+	WaitlistStatusWaiting WaitlistStatus = "waiting"
+	// WaitlistStatusOffered entries hold the freed time until the offer expires
+	WaitlistStatusOffered WaitlistStatus = "offered"
+	// WaitlistStatusClaimed entries were turned into a booking
+	WaitlistStatusClaimed WaitlistStatus = "claimed"
+	// WaitlistStatusExpired entries let their offer lapse or outlived the session
+	WaitlistStatusExpired WaitlistStatus = "expired"
+	// WaitlistStatusCancelled entries were withdrawn by the user
+	WaitlistStatusCancelled WaitlistStatus = "cancelled"
+)
+
+// WaitlistEntry queues a user for a session with an expert that is currently taken
+type WaitlistEntry struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	ExpertID       uint           `gorm:"not null;index:idx_waitlist_entries_expert_start,priority:1" json:"expert_id"`
+	UserID         uint           `gorm:"not null;index" json:"user_id"`
+	OfferingID     *uint          `json:"offering_id,omitempty"`
+	StartAt        time.Time      `gorm:"not null;index:idx_waitlist_entries_expert_start,priority:2" json:"start_at"`
+	EndAt          time.Time      `gorm:"not null" json:"end_at"`
+	Status         WaitlistStatus `gorm:"type:varchar(20);not null;default:waiting;index" json:"status"`
+	HoldID         *uint          `json:"hold_id,omitempty"`          // Slot hold offered to the user, confirm it to book
+	OfferExpiresAt *time.Time     `json:"offer_expires_at,omitempty"` // The offer passes to the next user after this
+	Expert         Expert         `gorm:"foreignKey:ExpertID" json:"-"`
+	User           User           `gorm:"foreignKey:UserID" json:"-"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
@@ -0,0 +1,155 @@
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FakeProviderName is the name the fake provider registers under
+const FakeProviderName = "fake"
+
+// FakeProvider is an in-process provider for development and tests. It never moves
+// money and behaves deterministically: intent IDs derive from the payment reference,
+// captures succeed unless the reference was declined and refunds succeed up to the
+// captured amount, once per reference. Webhooks are JSON encoded events signed as
+// described at SignatureHeader.
+type FakeProvider struct {
+	webhookSecret    string
+	webhookTolerance time.Duration
+
+	mu       sync.Mutex
+	intents  map[string]*Intent
+	declined map[string]bool
+	refunded map[string]int64
+	refunds  map[string]*Refund // By reference
+	made     int
+}
+
+// NewFakeProvider returns a fake provider accepting webhooks signed with webhookSecret
+// no more than tolerance ago
+func NewFakeProvider(webhookSecret string, tolerance time.Duration) *FakeProvider {
+	return &FakeProvider{
+		webhookSecret:    webhookSecret,
+		webhookTolerance: tolerance,
+		intents:          map[string]*Intent{},
+		declined:         map[string]bool{},
+		refunded:         map[string]int64{},
+		refunds:          map[string]*Refund{},
+	}
+}
+
+// Decline makes captures of the intent created for reference fail
+func (p *FakeProvider) Decline(reference string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.declined["fake_pi_"+reference] = true
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error) {
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be positive")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := "fake_pi_" + req.Reference
+	if intent, ok := p.intents[id]; ok {
+		copied := *intent
+		return &copied, nil
+	}
+
+	intent := &Intent{
+		ID:           id,
+		Status:       IntentRequiresCapture,
+		Amount:       req.Amount,
+		Currency:     req.Currency,
+		ClientSecret: id + "_secret",
+	}
+	p.intents[id] = intent
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *FakeProvider) Capture(ctx context.Context, intentID string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrUnknownIntent
+	}
+
+	intent.Status = IntentSucceeded
+	if p.declined[intentID] {
+		intent.Status = IntentFailed
+	}
+
+	copied := *intent
+	return &copied, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, req RefundRequest) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if refund, ok := p.refunds[req.Reference]; ok {
+		copied := *refund
+		return &copied, nil
+	}
+
+	intent, ok := p.intents[req.IntentID]
+	if !ok {
+		return nil, ErrUnknownIntent
+	}
+	if intent.Status != IntentSucceeded {
+		return nil, errors.New("only captured payments can be refunded")
+	}
+	if req.Amount <= 0 || p.refunded[req.IntentID]+req.Amount > intent.Amount {
+		return nil, errors.New("refund exceeds the captured amount")
+	}
+
+	p.refunded[req.IntentID] += req.Amount
+	p.made++
+	refund := &Refund{
+		ID:        fmt.Sprintf("fake_re_%d", p.made),
+		IntentID:  req.IntentID,
+		Amount:    req.Amount,
+		Reference: req.Reference,
+	}
+	if req.Reference != "" {
+		p.refunds[req.Reference] = refund
+	}
+
+	copied := *refund
+	return &copied, nil
+}
+
+// ParseWebhook verifies the signature of a JSON encoded Event and decodes it
+func (p *FakeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := VerifySignature(payload, header.Get(SignatureHeader), p.webhookSecret, p.webhookTolerance, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	if event.ID == "" || event.IntentID == "" {
+		return nil, errors.New("webhook event is missing its id or intent id")
+	}
+	if event.Type == EventRefunded && event.RefundID == "" {
+		return nil, errors.New("refund event is missing its refund id")
+	}
+	return &event, nil
+}
@@ -0,0 +1,112 @@
+// Package payments abstracts the payment service providers used to charge for
+// bookings. Providers register themselves by name and are looked up from config.
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+type IntentStatus string
+
+const (
+	// IntentRequiresCapture intents were authorized by the payer and wait to be captured
+	IntentRequiresCapture IntentStatus = "requires_capture"
+	IntentSucceeded       IntentStatus = "succeeded"
+	IntentFailed          IntentStatus = "failed"
+)
+
+type EventType string
+
+const (
+	EventPaymentSucceeded EventType = "payment.succeeded"
+	EventPaymentFailed    EventType = "payment.failed"
+	EventRefunded         EventType = "payment.refunded"
+)
+
+// IntentRequest asks a provider to collect an amount
+type IntentRequest struct {
+	Amount    int64  // In minor units, e.g. cents
+	Currency  string // ISO 4217 code in upper case, e.g. "USD"
+	Reference string // Our payment ID, echoed back in webhooks
+}
+
+// Intent is a payment a provider was asked to collect
+type Intent struct {
+	ID           string
+	Status       IntentStatus
+	Amount       int64
+	Currency     string
+	ClientSecret string // Handed to the client to complete the payment with the provider
+}
+
+// RefundRequest asks a provider to return part of a captured intent
+type RefundRequest struct {
+	IntentID  string
+	Amount    int64  // In minor units of the intent's currency
+	Reference string // Our refund ID, echoed back in webhooks
+}
+
+// Refund is money returned to the payer of an intent
+type Refund struct {
+	ID        string
+	IntentID  string
+	Amount    int64
+	Reference string
+}
+
+// Event is a provider notification about an intent, received through a webhook
+type Event struct {
+	ID       string    `json:"id"` // Unique per event, used to ignore redeliveries
+	Type     EventType `json:"type"`
+	IntentID string    `json:"intent_id"`
+	Amount   int64     `json:"amount"`   // In minor units of Currency
+	Currency string    `json:"currency"` // ISO 4217 code
+	// RefundID and RefundReference identify the refund of refund events
+	RefundID        string `json:"refund_id,omitempty"`
+	RefundReference string `json:"refund_reference,omitempty"`
+}
+
+// PaymentProvider is implemented by every payment service provider
+type PaymentProvider interface {
+	// Name identifies the provider in config, payments and webhook URLs
+	Name() string
+	CreateIntent(ctx context.Context, req IntentRequest) (*Intent, error)
+	Capture(ctx context.Context, intentID string) (*Intent, error)
+	// Refund returns part of a captured intent to the payer. Repeating a request with
+	// the same reference returns the refund made for it instead of refunding again.
+	Refund(ctx context.Context, req RefundRequest) (*Refund, error)
+	// ParseWebhook verifies and decodes a webhook delivery
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+// ErrUnknownIntent is returned by providers for intents they don't know
+var ErrUnknownIntent = errors.New("unknown payment intent")
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]PaymentProvider{}
+)
+
+// Register makes a provider available under its name, replacing any provider
+// registered under the same name
+func Register(provider PaymentProvider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[provider.Name()] = provider
+}
+
+// Get returns the provider registered under name
+func Get(name string) (PaymentProvider, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	provider, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("payment provider %q is not registered", name)
+	}
+	return provider, nil
+}
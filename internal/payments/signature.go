@@ -0,0 +1,80 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader carries the webhook signature in the form "t=<unix seconds>,v1=<hex>".
+// v1 is the HMAC-SHA256 of "<t>.<payload>" keyed with the webhook secret and may be
+// repeated while secrets are rotated.
+const SignatureHeader = "Payment-Signature"
+
+var (
+	// ErrInvalidSignature is returned for webhooks whose signature doesn't match the payload
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+
+	// ErrStaleWebhook is returned for webhooks signed too long ago, which could be replays
+	ErrStaleWebhook = errors.New("webhook timestamp is outside the tolerance")
+)
+
+// Sign returns the signature header value for payload sent at the given time
+func Sign(payload []byte, secret string, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + signature(payload, secret, timestamp)
+}
+
+// VerifySignature checks a signature header produced by Sign against the payload and
+// rejects timestamps more than tolerance away from now
+func VerifySignature(payload []byte, header, secret string, tolerance time.Duration, now time.Time) error {
+	if secret == "" {
+		return errors.New("no webhook secret is configured")
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+		return ErrStaleWebhook
+	}
+
+	expected := signature(payload, secret, timestamp)
+	for _, candidate := range signatures {
+		if hmac.Equal([]byte(candidate), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func signature(payload []byte, secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,57 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"payment.succeeded"}`)
+	secret := "whsec_test"
+	tolerance := 5 * time.Minute
+	signedAt := time.Unix(1700000000, 0)
+
+	valid := Sign(payload, secret, signedAt)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		secret  string
+		now     time.Time
+		wantErr error
+	}{
+		{name: "valid", payload: payload, header: valid, secret: secret, now: signedAt},
+		{name: "within tolerance", payload: payload, header: valid, secret: secret, now: signedAt.Add(tolerance)},
+		{name: "rotated secret", payload: payload, header: valid + ",v1=" + signature(payload, "whsec_new", "1700000000"), secret: "whsec_new", now: signedAt},
+		{name: "other payload", payload: []byte(`{"id":"evt_2"}`), header: valid, secret: secret, now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "other secret", payload: payload, header: valid, secret: "whsec_other", now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "bad signature", payload: payload, header: "t=1700000000,v1=deadbeef", secret: secret, now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "stale", payload: payload, header: valid, secret: secret, now: signedAt.Add(tolerance + time.Second), wantErr: ErrStaleWebhook},
+		{name: "from the future", payload: payload, header: valid, secret: secret, now: signedAt.Add(-tolerance - time.Second), wantErr: ErrStaleWebhook},
+		{name: "empty header", payload: payload, header: "", secret: secret, now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "no timestamp", payload: payload, header: "v1=" + signature(payload, secret, "1700000000"), secret: secret, now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "no signature", payload: payload, header: "t=1700000000", secret: secret, now: signedAt, wantErr: ErrInvalidSignature},
+		{name: "bad timestamp", payload: payload, header: "t=yesterday,v1=deadbeef", secret: secret, now: signedAt, wantErr: ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySignature(tt.payload, tt.header, tt.secret, tolerance, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifySignature() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureWithoutSecret(t *testing.T) {
+	payload := []byte(`{}`)
+	now := time.Unix(1700000000, 0)
+
+	// A header signed with an empty key must not pass while no secret is configured
+	if err := VerifySignature(payload, Sign(payload, "", now), "", time.Minute, now); err == nil {
+		t.Fatal("VerifySignature() accepted a webhook without a configured secret")
+	}
+}
@@ -9,6 +9,27 @@ import (
 func BookingRoutes(e *echo.Echo) {
 	g := e.Group("/bookings")
 	g.Use(middleware.AuthMiddleware)
+	g.Use(middleware.Idempotency)
 
+	g.GET("", handlers.GetMyBookings)
+	g.GET("/quote", handlers.GetBookingQuote)
 	g.POST("/create-booking", handlers.CreateBooking)
+	g.POST("/series", handlers.CreateBookingSeries)
+
+	// Checkout hold routes
+	g.POST("/holds", handlers.CreateSlotHold)
+	g.POST("/holds/:id/confirm", handlers.ConfirmSlotHold)
+	g.DELETE("/holds/:id", handlers.ReleaseSlotHold)
+
+	// Waitlist routes
+	g.POST("/waitlist", handlers.JoinWaitlist)
+	g.GET("/waitlist", handlers.GetMyWaitlist)
+	g.DELETE("/waitlist/:id", handlers.LeaveWaitlist)
+
+	// Lifecycle routes
+	g.POST("/:id/cancel", handlers.CancelBooking)
+	g.POST("/:id/reschedule", handlers.RescheduleBooking)
+	g.POST("/:id/complete", handlers.CompleteBooking)
+	g.POST("/:id/no-show", handlers.MarkBookingNoShow)
+	g.GET("/:id/history", handlers.GetBookingHistory)
 }
@@ -14,6 +14,9 @@ func ExpertRoutes(e *echo.Echo) {
 	g.GET("/search", handlers.GetExpertByCatergoryName)
 	g.GET("/get-expert-by-id/:id", handlers.GetExpertById)
 	g.GET("/available-slots", handlers.GetAvailableSlots)
+	g.GET("/available-slots/range", handlers.GetAvailabilityRange)
+	g.GET("/next-available", handlers.GetNextAvailable)
+	g.GET("/cancellation-policy/:id", handlers.GetCancellationPolicy)
 
 	// Protected routes (auth required)
 	g.Use(middleware.AuthMiddleware)
@@ -27,4 +30,24 @@ func ExpertRoutes(e *echo.Echo) {
 	g.GET("/availability", handlers.GetAvailability)
 	g.PATCH("/availability/:id", handlers.UpdateAvailability)
 	g.DELETE("/availability/:id", handlers.DeleteAvailability)
+	g.PUT("/availability/schedule", handlers.ReplaceSchedule)
+
+	// Availability override routes
+	g.POST("/availability/overrides", handlers.CreateAvailabilityOverride)
+	g.GET("/availability/overrides", handlers.GetAvailabilityOverrides)
+	g.DELETE("/availability/overrides/:id", handlers.DeleteAvailabilityOverride)
+
+	// Offering routes
+	g.POST("/offerings", handlers.CreateOffering)
+	g.GET("/offerings", handlers.GetOfferings)
+	g.PATCH("/offerings/:id", handlers.UpdateOffering)
+	g.DELETE("/offerings/:id", handlers.DeleteOffering)
+
+	// Cancellation policy routes
+	g.PUT("/cancellation-policy", handlers.ReplaceCancellationPolicy)
+
+	// Booking routes
+	g.GET("/bookings", handlers.GetExpertBookings)
+	g.POST("/bookings/:id/accept", handlers.AcceptBooking)
+	g.POST("/bookings/:id/decline", handlers.DeclineBooking)
 }
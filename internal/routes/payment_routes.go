@@ -1,10 +1,20 @@
 package routes
 
 import (
+	"github.com/devlpr-nitish/appointment-booking-go/internal/handlers"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/middleware"
 	"github.com/labstack/echo/v4"
 )
 
 func PaymentRoutes(e *echo.Echo) {
-	_ = e.Group("/payments")
-	// g.POST("", handlers.CreatePayment)
+	// Providers authenticate webhooks with a signature instead of a user token
+	e.POST("/payments/webhook/:provider", handlers.PaymentWebhook)
+
+	g := e.Group("/payments")
+	g.Use(middleware.AuthMiddleware)
+	g.Use(middleware.Idempotency)
+
+	g.POST("/checkout", handlers.Checkout)
+	g.GET("/:id", handlers.GetPayment)
+	g.POST("/:id/capture", handlers.CapturePayment)
 }
@@ -1,11 +1,15 @@
 package routes
 
 import (
+	"github.com/devlpr-nitish/appointment-booking-go/internal/handlers"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/middleware"
 	"github.com/labstack/echo/v4"
 )
 
 func UserRoutes(e *echo.Echo) {
-	_ = e.Group("/users")
-	// g.GET("", handlers.GetUsers)
-	// g.POST("", handlers.CreateUser)
+	g := e.Group("/users")
+	g.Use(middleware.AuthMiddleware)
+
+	g.GET("/me", handlers.GetMe)
+	g.PATCH("/me", handlers.UpdateMe)
 }
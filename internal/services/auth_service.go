@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
@@ -51,7 +52,7 @@ func RegisterUser(email, password, name, role string) (*models.User, error) {
 			UserID:     user.ID,
 			Bio:        "",
 			Expertise:  "",
-			HourlyRate: 0,
+			HourlyRate: models.Money{Currency: config.GetConfig().DefaultCurrency},
 			IsVerified: false,
 		}
 		if err := db.Create(&expert).Error; err != nil {
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxBlackoutDays caps the length of a single blackout so that typos such as a wrong
+// year don't close an expert's calendar for good
+const maxBlackoutDays = 366
+
+// AvailabilityOverrideInput describes a date-specific exception to the weekly rules
+type AvailabilityOverrideInput struct {
+	Kind      models.OverrideKind
+	Date      string // Format: "YYYY-MM-DD"
+	EndDate   string // Format: "YYYY-MM-DD", blackouts only, defaults to Date
+	StartTime string // Format: "HH:MM", blocks and extra windows only
+	EndTime   string // Format: "HH:MM", blocks and extra windows only
+	Reason    string
+}
+
+// CreateAvailabilityOverride adds a blackout, block or extra window to the expert's
+// availability
+func CreateAvailabilityOverride(expertID uint, input AvailabilityOverrideInput) (*models.AvailabilityOverride, error) {
+	db := database.GetDB()
+
+	if _, err := getExpertByID(db, expertID); err != nil {
+		return nil, err
+	}
+
+	override := models.AvailabilityOverride{
+		ExpertID:  expertID,
+		Kind:      input.Kind,
+		Date:      input.Date,
+		EndDate:   input.EndDate,
+		StartTime: input.StartTime,
+		EndTime:   input.EndTime,
+		Reason:    input.Reason,
+	}
+
+	if err := normalizeOverride(&override); err != nil {
+		return nil, err
+	}
+
+	if err := db.Create(&override).Error; err != nil {
+		return nil, err
+	}
+
+	return &override, nil
+}
+
+// GetAvailabilityOverrides returns the expert's overrides touching the dates from..to.
+// Empty bounds are open ended.
+func GetAvailabilityOverrides(expertID uint, from, to string) ([]models.AvailabilityOverride, error) {
+	db := database.GetDB()
+
+	query := db.Where("expert_id = ?", expertID)
+	if from != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			return nil, errors.New("invalid from date, expected YYYY-MM-DD")
+		}
+		query = query.Where("end_date >= ?", from)
+	}
+	if to != "" {
+		if _, err := time.Parse("2006-01-02", to); err != nil {
+			return nil, errors.New("invalid to date, expected YYYY-MM-DD")
+		}
+		query = query.Where("date <= ?", to)
+	}
+
+	var overrides []models.AvailabilityOverride
+	if err := query.Order("date ASC, start_time ASC").Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
+// DeleteAvailabilityOverride removes one of the expert's overrides
+func DeleteAvailabilityOverride(id, expertID uint) error {
+	db := database.GetDB()
+
+	result := db.Where("id = ? AND expert_id = ?", id, expertID).Delete(&models.AvailabilityOverride{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("availability override not found")
+	}
+
+	return nil
+}
+
+// normalizeOverride validates an override and fills in its end date
+func normalizeOverride(override *models.AvailabilityOverride) error {
+	date, err := time.Parse("2006-01-02", override.Date)
+	if err != nil {
+		return errors.New("invalid date format, expected YYYY-MM-DD")
+	}
+
+	switch override.Kind {
+	case models.OverrideBlackout:
+		if override.StartTime != "" || override.EndTime != "" {
+			return errors.New("blackouts cover whole days and take no start or end time")
+		}
+
+		if override.EndDate == "" {
+			override.EndDate = override.Date
+		}
+		endDate, err := time.Parse("2006-01-02", override.EndDate)
+		if err != nil {
+			return errors.New("invalid end date format, expected YYYY-MM-DD")
+		}
+		if endDate.Before(date) {
+			return errors.New("end date must not be before the date")
+		}
+		if endDate.Sub(date) >= maxBlackoutDays*24*time.Hour {
+			return errors.New("a blackout may span at most 366 days")
+		}
+
+	case models.OverrideBlock, models.OverrideExtra:
+		if override.EndDate != "" && override.EndDate != override.Date {
+			return errors.New("blocks and extra windows apply to a single date")
+		}
+		override.EndDate = override.Date
+
+		start, err := parseClock(override.StartTime)
+		if err != nil {
+			return errors.New("invalid start time format, expected HH:MM")
+		}
+		end, err := parseClock(override.EndTime)
+		if err != nil {
+			return errors.New("invalid end time format, expected HH:MM")
+		}
+		if end <= start {
+			return errors.New("end time must be after start time")
+		}
+
+	default:
+		return errors.New("kind must be one of blackout, block or extra")
+	}
+
+	return nil
+}
+
+// loadOverrides returns the expert's overrides on the local dates, read in loc, that
+// intersect [from, to). The previous date is included like in windowsFromRules.
+func loadOverrides(db *gorm.DB, expertID uint, loc *time.Location, from, to time.Time) ([]models.AvailabilityOverride, error) {
+	firstDate := from.In(loc).AddDate(0, 0, -1).Format("2006-01-02")
+	lastDate := to.In(loc).Format("2006-01-02")
+
+	overrides, err := loadOverridesByExpert(db, []uint{expertID}, firstDate, lastDate)
+	if err != nil {
+		return nil, err
+	}
+	return overrides[expertID], nil
+}
+
+// loadOverridesByExpert returns the overrides of several experts touching the dates
+// firstDate..lastDate, keyed by expert
+func loadOverridesByExpert(db *gorm.DB, expertIDs []uint, firstDate, lastDate string) (map[uint][]models.AvailabilityOverride, error) {
+	var overrides []models.AvailabilityOverride
+	if err := db.Where("expert_id IN ? AND date <= ? AND end_date >= ?", expertIDs, lastDate, firstDate).
+		Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+
+	byExpert := make(map[uint][]models.AvailabilityOverride, len(expertIDs))
+	for _, override := range overrides {
+		byExpert[override.ExpertID] = append(byExpert[override.ExpertID], override)
+	}
+	return byExpert, nil
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"log"
+	"sort"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// availabilityWindow is a concrete time range in which the expert takes sessions
+type availabilityWindow struct {
+	Start  time.Time
+	End    time.Time
+	SlotID uint // Weekly availability rule the window comes from, 0 for extra windows
+}
+
+// loadLocation resolves an IANA time zone name such as "Europe/Berlin". An empty
+// name means UTC.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, invalidInput("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// expertLocation returns the time zone the expert's availability is written in
+func expertLocation(expert *models.Expert) *time.Location {
+	loc, err := loadLocation(expert.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// loadWeeklyRules returns all weekly availability rules of an expert
+func loadWeeklyRules(db *gorm.DB, expertID uint) ([]models.AvailabilitySlot, error) {
+	var weekly []models.AvailabilitySlot
+	if err := db.Where("expert_id = ?", expertID).
+		Order("day_of_week ASC, start_time ASC").
+		Find(&weekly).Error; err != nil {
+		return nil, err
+	}
+	return weekly, nil
+}
+
+// loadWeeklyRulesByExpert returns the weekly availability rules of several experts,
+// keyed by expert
+func loadWeeklyRulesByExpert(db *gorm.DB, expertIDs []uint) (map[uint][]models.AvailabilitySlot, error) {
+	var weekly []models.AvailabilitySlot
+	if err := db.Where("expert_id IN ?", expertIDs).
+		Order("day_of_week ASC, start_time ASC").
+		Find(&weekly).Error; err != nil {
+		return nil, err
+	}
+
+	byExpert := make(map[uint][]models.AvailabilitySlot, len(expertIDs))
+	for _, rule := range weekly {
+		byExpert[rule.ExpertID] = append(byExpert[rule.ExpertID], rule)
+	}
+	return byExpert, nil
+}
+
+// windowsFromRules expands weekly rules, read as wall-clock times in loc, into the
+// concrete windows that intersect [from, to). Each date is resolved on its own, so
+// windows keep their local hours across DST changes. Rules are validated when they
+// are saved; a malformed legacy rule is logged and skipped.
+func windowsFromRules(weekly []models.AvailabilitySlot, loc *time.Location, from, to time.Time) []availabilityWindow {
+	windows := []availabilityWindow{}
+
+	// Start a day early so that windows of the previous local date are considered
+	first := from.In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day()-1, 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, rule := range weekly {
+			if rule.DayOfWeek != int(day.Weekday()) {
+				continue
+			}
+
+			start, err := combineDateAndClock(day, rule.StartTime)
+			if err != nil {
+				log.Printf("Skipping availability rule %d: %v", rule.ID, err)
+				continue
+			}
+			endDay := day
+			if rule.CrossesMidnight {
+				endDay = day.AddDate(0, 0, 1)
+			}
+			end, err := combineDateAndClock(endDay, rule.EndTime)
+			if err != nil {
+				log.Printf("Skipping availability rule %d: %v", rule.ID, err)
+				continue
+			}
+
+			if start.Before(to) && end.After(from) {
+				windows = append(windows, availabilityWindow{Start: start, End: end, SlotID: rule.ID})
+			}
+		}
+	}
+
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Start.Before(windows[j].Start)
+	})
+
+	return windows
+}
+
+// loadExpertWindows returns the expert's availability windows intersecting [from, to):
+// the weekly rules with the date-specific overrides applied
+func loadExpertWindows(db *gorm.DB, expert *models.Expert, from, to time.Time) ([]availabilityWindow, error) {
+	loc := expertLocation(expert)
+
+	weekly, err := loadWeeklyRules(db, expert.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides, err := loadOverrides(db, expert.ID, loc, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return applyOverrides(windowsFromRules(weekly, loc, from, to), overrides, loc, from, to), nil
+}
+
+// applyOverrides merges date-specific overrides, read in loc, into the windows
+// intersecting [from, to). Extra windows are added first, so that blocks and
+// blackouts win over them.
+func applyOverrides(windows []availabilityWindow, overrides []models.AvailabilityOverride, loc *time.Location, from, to time.Time) []availabilityWindow {
+	if len(overrides) == 0 {
+		return windows
+	}
+
+	var removed []busyRange
+	for _, override := range overrides {
+		date, err := time.ParseInLocation("2006-01-02", override.Date, loc)
+		if err != nil {
+			log.Printf("Skipping availability override %d: %v", override.ID, err)
+			continue
+		}
+
+		if override.Kind == models.OverrideBlackout {
+			endDate, err := time.ParseInLocation("2006-01-02", override.EndDate, loc)
+			if err != nil {
+				log.Printf("Skipping availability override %d: %v", override.ID, err)
+				continue
+			}
+			removed = append(removed, busyRange{Start: date, End: endDate.AddDate(0, 0, 1)})
+			continue
+		}
+
+		start, err := combineDateAndClock(date, override.StartTime)
+		if err != nil {
+			log.Printf("Skipping availability override %d: %v", override.ID, err)
+			continue
+		}
+		end, err := combineDateAndClock(date, override.EndTime)
+		if err != nil {
+			log.Printf("Skipping availability override %d: %v", override.ID, err)
+			continue
+		}
+
+		switch override.Kind {
+		case models.OverrideExtra:
+			if start.Before(to) && end.After(from) {
+				windows = append(windows, availabilityWindow{Start: start, End: end})
+			}
+		case models.OverrideBlock:
+			removed = append(removed, busyRange{Start: start, End: end})
+		}
+	}
+
+	windows = mergeWindows(windows)
+	for _, r := range removed {
+		windows = subtractRange(windows, r)
+	}
+
+	return windows
+}
+
+// mergeWindows sorts windows and joins the ones that overlap, so that an extra window
+// on top of a weekly one doesn't produce duplicate slots
+func mergeWindows(windows []availabilityWindow) []availabilityWindow {
+	sort.Slice(windows, func(i, j int) bool {
+		return windows[i].Start.Before(windows[j].Start)
+	})
+
+	merged := []availabilityWindow{}
+	for _, window := range windows {
+		last := len(merged) - 1
+		if last >= 0 && window.Start.Before(merged[last].End) {
+			if window.End.After(merged[last].End) {
+				merged[last].End = window.End
+			}
+			continue
+		}
+		merged = append(merged, window)
+	}
+
+	return merged
+}
+
+// subtractRange cuts r out of the windows, splitting windows that contain it
+func subtractRange(windows []availabilityWindow, r busyRange) []availabilityWindow {
+	result := []availabilityWindow{}
+
+	for _, window := range windows {
+		if !r.Start.Before(window.End) || !r.End.After(window.Start) {
+			result = append(result, window)
+			continue
+		}
+
+		if window.Start.Before(r.Start) {
+			result = append(result, availabilityWindow{Start: window.Start, End: r.Start, SlotID: window.SlotID})
+		}
+		if r.End.Before(window.End) {
+			result = append(result, availabilityWindow{Start: r.End, End: window.End, SlotID: window.SlotID})
+		}
+	}
+
+	return result
+}
+
+// slotGenerator cuts availability windows into bookable sessions
+type slotGenerator struct {
+	Windows []availabilityWindow
+	Busy    []busyRange
+	Rules   bookingRules
+	Session sessionType
+	Now     time.Time
+}
+
+// slots returns the sessions starting in [from, to), with times expressed in loc.
+// Sessions are generated back to back from the start of each window and must fit
+// entirely inside it. A session has no seats left when a booking or hold of another
+// session overlaps it or its buffers, so a long booking blocks every shorter start it
+// covers, and when it starts outside the expert's notice and horizon. Bookings and
+// holds of the same group session take one seat each.
+func (g slotGenerator) slots(from, to time.Time, loc *time.Location) []TimeSlot {
+	timeSlots := []TimeSlot{}
+	g.each(from, to, loc, func(slot TimeSlot) bool {
+		timeSlots = append(timeSlots, slot)
+		return true
+	})
+	return timeSlots
+}
+
+// firstAvailable returns the earliest available session starting in [from, to)
+func (g slotGenerator) firstAvailable(from, to time.Time, loc *time.Location) (TimeSlot, bool) {
+	var first TimeSlot
+	found := false
+	g.each(from, to, loc, func(slot TimeSlot) bool {
+		if slot.Available {
+			first, found = slot, true
+		}
+		return !found
+	})
+	return first, found
+}
+
+// each passes the sessions starting in [from, to) to fn in chronological order until
+// fn returns false. The windows must be sorted and must not overlap.
+func (g slotGenerator) each(from, to time.Time, loc *time.Location, fn func(TimeSlot) bool) {
+	duration := g.Session.Duration
+	for _, window := range g.Windows {
+		for start := window.Start; !start.Add(duration).After(window.End); start = start.Add(duration) {
+			if start.Before(from) || !start.Before(to) {
+				continue
+			}
+			end := start.Add(duration)
+
+			seats := 0
+			if g.Rules.withinBookingWindow(start, g.Now) {
+				seats = g.Rules.seatsLeft(g.Busy, start, end, g.Session.key(start), g.Session.Capacity)
+			}
+
+			slot := TimeSlot{
+				Time:           start.In(loc).Format("15:04"),
+				EndTime:        end.In(loc).Format("15:04"),
+				StartAt:        start.In(loc),
+				EndAt:          end.In(loc),
+				Capacity:       g.Session.Capacity,
+				SeatsRemaining: seats,
+				Available:      seats > 0,
+				ID:             window.SlotID,
+			}
+			if !fn(slot) {
+				return
+			}
+		}
+	}
+}
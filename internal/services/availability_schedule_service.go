@@ -0,0 +1,229 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+// maxScheduleWindows bounds the number of windows in one weekly schedule
+const maxScheduleWindows = 100
+
+// minutesPerDay and minutesPerWeek measure weekly windows in minutes since Sunday 00:00
+const (
+	minutesPerDay  = 24 * 60
+	minutesPerWeek = 7 * minutesPerDay
+)
+
+// WeeklyWindow is one window of a weekly schedule
+type WeeklyWindow struct {
+	DayOfWeek int    // 0-6 (Sunday-Saturday)
+	StartTime string // Format: "HH:MM"
+	EndTime   string // Format: "HH:MM"
+	// CrossesMidnight means the window ends at EndTime on the following day
+	CrossesMidnight bool
+}
+
+// weeklyWindowOf returns the window described by a weekly rule
+func weeklyWindowOf(rule models.AvailabilitySlot) WeeklyWindow {
+	return WeeklyWindow{
+		DayOfWeek:       rule.DayOfWeek,
+		StartTime:       rule.StartTime,
+		EndTime:         rule.EndTime,
+		CrossesMidnight: rule.CrossesMidnight,
+	}
+}
+
+// problems returns everything wrong with the window on its own
+func (w WeeklyWindow) problems() []string {
+	var problems []string
+
+	if w.DayOfWeek < 0 || w.DayOfWeek > 6 {
+		problems = append(problems, "day of week must be between 0 (Sunday) and 6 (Saturday)")
+	}
+
+	start, startErr := parseClock(w.StartTime)
+	if startErr != nil {
+		problems = append(problems, "invalid start time format, expected HH:MM")
+	}
+	end, endErr := parseClock(w.EndTime)
+	if endErr != nil {
+		problems = append(problems, "invalid end time format, expected HH:MM")
+	}
+
+	if startErr == nil && endErr == nil {
+		if w.CrossesMidnight && end > start {
+			problems = append(problems, "a window crossing midnight must end before it starts on the clock")
+		} else if !w.CrossesMidnight && end <= start {
+			problems = append(problems, "end time must be after start time, set crosses_midnight for overnight windows")
+		}
+	}
+
+	return problems
+}
+
+// span returns the window as minutes since Sunday 00:00. Windows crossing midnight on
+// Saturday end past the end of the week. The window must be valid.
+func (w WeeklyWindow) span() (start, end int) {
+	startClock, _ := parseClock(w.StartTime)
+	endClock, _ := parseClock(w.EndTime)
+
+	start = w.DayOfWeek*minutesPerDay + startClock
+	end = w.DayOfWeek*minutesPerDay + endClock
+	if w.CrossesMidnight {
+		end += minutesPerDay
+	}
+	return start, end
+}
+
+// overlaps reports whether two valid windows share any time, including a Saturday
+// night window running into Sunday morning
+func (w WeeklyWindow) overlaps(other WeeklyWindow) bool {
+	start, end := w.span()
+	otherStart, otherEnd := other.span()
+
+	for _, shift := range []int{-minutesPerWeek, 0, minutesPerWeek} {
+		if start < otherEnd+shift && otherStart+shift < end {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleWindowError lists the problems found with one window of a schedule
+type ScheduleWindowError struct {
+	Index  int      `json:"index"` // Position of the window in the submitted schedule
+	Errors []string `json:"errors"`
+}
+
+// ScheduleValidationError is returned when a weekly schedule is rejected. It reports
+// every invalid window rather than only the first one.
+type ScheduleValidationError struct {
+	Windows []ScheduleWindowError
+}
+
+func (e *ScheduleValidationError) Error() string {
+	return "the weekly schedule contains invalid windows"
+}
+
+// ReplaceWeeklySchedule validates a complete weekly schedule and replaces the expert's
+// weekly rules with it in a single transaction. Rules that appear unchanged in the new
+// schedule keep their IDs, so bookings stay linked to them.
+func ReplaceWeeklySchedule(expertID uint, windows []WeeklyWindow) ([]models.AvailabilitySlot, error) {
+	if err := validateWeeklySchedule(windows); err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	tx := db.Begin()
+
+	// Serialize with concurrent schedule changes and bookings of the expert
+	if _, err := lockExpert(tx, expertID); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	existing, err := loadWeeklyRules(tx, expertID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	kept := make(map[WeeklyWindow]bool, len(existing))
+	var obsolete []uint
+	for _, rule := range existing {
+		key := weeklyWindowOf(rule)
+		if containsWindow(windows, key) && !kept[key] {
+			kept[key] = true
+			continue
+		}
+		obsolete = append(obsolete, rule.ID)
+	}
+
+	if len(obsolete) > 0 {
+		if err := tx.Where("id IN ?", obsolete).Delete(&models.AvailabilitySlot{}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	var added []models.AvailabilitySlot
+	for _, window := range windows {
+		if kept[window] {
+			continue
+		}
+		added = append(added, models.AvailabilitySlot{
+			ExpertID:        expertID,
+			DayOfWeek:       window.DayOfWeek,
+			StartTime:       window.StartTime,
+			EndTime:         window.EndTime,
+			CrossesMidnight: window.CrossesMidnight,
+		})
+	}
+
+	if len(added) > 0 {
+		if err := tx.Create(&added).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	schedule, err := loadWeeklyRules(tx, expertID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// validateWeeklySchedule checks every window on its own and against the others and
+// returns a *ScheduleValidationError describing all problems, or nil
+func validateWeeklySchedule(windows []WeeklyWindow) error {
+	if len(windows) > maxScheduleWindows {
+		return invalidInput("a weekly schedule may contain at most %d windows", maxScheduleWindows)
+	}
+
+	problems := make([][]string, len(windows))
+	for i, window := range windows {
+		problems[i] = window.problems()
+	}
+
+	for i := range windows {
+		for j := i + 1; j < len(windows); j++ {
+			if len(problems[i]) > 0 || len(problems[j]) > 0 {
+				continue
+			}
+			if windows[i].overlaps(windows[j]) {
+				problems[i] = append(problems[i], fmt.Sprintf("overlaps window %d", j))
+				problems[j] = append(problems[j], fmt.Sprintf("overlaps window %d", i))
+			}
+		}
+	}
+
+	var report ScheduleValidationError
+	for i, messages := range problems {
+		if len(messages) > 0 {
+			report.Windows = append(report.Windows, ScheduleWindowError{Index: i, Errors: messages})
+		}
+	}
+	if len(report.Windows) > 0 {
+		return &report
+	}
+
+	return nil
+}
+
+func containsWindow(windows []WeeklyWindow, window WeeklyWindow) bool {
+	for _, w := range windows {
+		if w == window {
+			return true
+		}
+	}
+	return false
+}
@@ -7,43 +7,40 @@ import (
 
 	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/utils"
 	"gorm.io/gorm"
 )
 
-// CreateAvailability creates a new availability slot for an expert
-func CreateAvailability(expertID uint, dayOfWeek int, startTime, endTime string) (*models.AvailabilitySlot, error) {
+// CreateAvailability creates a new availability slot for an expert. An overnight
+// window ends at endTime on the following day and must set crossesMidnight.
+func CreateAvailability(expertID uint, dayOfWeek int, startTime, endTime string, crossesMidnight bool) (*models.AvailabilitySlot, error) {
 	db := database.GetDB()
 
-	// Validate day of week
-	if dayOfWeek < 0 || dayOfWeek > 6 {
-		return nil, errors.New("day of week must be between 0 (Sunday) and 6 (Saturday)")
+	window := WeeklyWindow{DayOfWeek: dayOfWeek, StartTime: startTime, EndTime: endTime, CrossesMidnight: crossesMidnight}
+	if problems := window.problems(); len(problems) > 0 {
+		return nil, invalidInput("%s", problems[0])
 	}
 
 	// Validate that the expert exists
 	var expert models.Expert
 	if err := db.Where("id = ?", expertID).First(&expert).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("expert not found")
+			return nil, invalidInput("expert not found")
 		}
 		return nil, err
 	}
 
-	// Check for overlapping availability on the same day
-	var existingSlot models.AvailabilitySlot
-	err := db.Where("expert_id = ? AND day_of_week = ? AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?) OR (start_time >= ? AND end_time <= ?))",
-		expertID, dayOfWeek, startTime, startTime, endTime, endTime, startTime, endTime).First(&existingSlot).Error
-
-	if err == nil {
-		return nil, errors.New("availability slot overlaps with existing slot")
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+	// Check for overlapping availability, including overnight windows of adjacent days
+	if err := ensureNoOverlappingRule(db, expertID, window, 0); err != nil {
 		return nil, err
 	}
 
 	availability := models.AvailabilitySlot{
-		ExpertID:  expertID,
-		DayOfWeek: dayOfWeek,
-		StartTime: startTime,
-		EndTime:   endTime,
+		ExpertID:        expertID,
+		DayOfWeek:       dayOfWeek,
+		StartTime:       startTime,
+		EndTime:         endTime,
+		CrossesMidnight: crossesMidnight,
 	}
 
 	if err := db.Create(&availability).Error; err != nil {
@@ -66,30 +63,24 @@ func GetAvailabilityByExpertID(expertID uint) ([]models.AvailabilitySlot, error)
 }
 
 // UpdateAvailability updates an existing availability slot
-func UpdateAvailability(id, expertID uint, dayOfWeek int, startTime, endTime string) (*models.AvailabilitySlot, error) {
+func UpdateAvailability(id, expertID uint, dayOfWeek int, startTime, endTime string, crossesMidnight bool) (*models.AvailabilitySlot, error) {
 	db := database.GetDB()
 
-	// Validate day of week
-	if dayOfWeek < 0 || dayOfWeek > 6 {
-		return nil, errors.New("day of week must be between 0 (Sunday) and 6 (Saturday)")
+	window := WeeklyWindow{DayOfWeek: dayOfWeek, StartTime: startTime, EndTime: endTime, CrossesMidnight: crossesMidnight}
+	if problems := window.problems(); len(problems) > 0 {
+		return nil, invalidInput("%s", problems[0])
 	}
 
 	var availability models.AvailabilitySlot
 	if err := db.Where("id = ? AND expert_id = ?", id, expertID).First(&availability).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("availability slot not found")
+			return nil, ErrAvailabilityNotFound
 		}
 		return nil, err
 	}
 
-	// Check for overlapping availability on the same day (excluding current slot)
-	var existingSlot models.AvailabilitySlot
-	err := db.Where("expert_id = ? AND day_of_week = ? AND id != ? AND ((start_time <= ? AND end_time > ?) OR (start_time < ? AND end_time >= ?) OR (start_time >= ? AND end_time <= ?))",
-		expertID, dayOfWeek, id, startTime, startTime, endTime, endTime, startTime, endTime).First(&existingSlot).Error
-
-	if err == nil {
-		return nil, errors.New("availability slot overlaps with existing slot")
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+	// Check for overlapping availability (excluding current slot)
+	if err := ensureNoOverlappingRule(db, expertID, window, id); err != nil {
 		return nil, err
 	}
 
@@ -97,6 +88,7 @@ func UpdateAvailability(id, expertID uint, dayOfWeek int, startTime, endTime str
 	availability.DayOfWeek = dayOfWeek
 	availability.StartTime = startTime
 	availability.EndTime = endTime
+	availability.CrossesMidnight = crossesMidnight
 
 	if err := db.Save(&availability).Error; err != nil {
 		return nil, err
@@ -115,92 +107,322 @@ func DeleteAvailability(id, expertID uint) error {
 	}
 
 	if result.RowsAffected == 0 {
-		return fmt.Errorf("availability slot not found or you don't have permission to delete it")
+		return ErrAvailabilityNotFound
 	}
 
 	return nil
 }
 
-// TimeSlot represents a bookable time slot
-type TimeSlot struct {
-	Time      string `json:"time"`
-	Available bool   `json:"available"`
-	ID        uint   `json:"id"`
+// ensureNoOverlappingRule returns an error when the window overlaps one of the
+// expert's weekly rules other than excludeID. The window must be valid.
+func ensureNoOverlappingRule(db *gorm.DB, expertID uint, window WeeklyWindow, excludeID uint) error {
+	weekly, err := loadWeeklyRules(db, expertID)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range weekly {
+		if rule.ID == excludeID {
+			continue
+		}
+		// Rules stored before clock times were validated are skipped
+		if len(weeklyWindowOf(rule).problems()) > 0 {
+			continue
+		}
+		if window.overlaps(weeklyWindowOf(rule)) {
+			return invalidInput("availability slot overlaps with existing slot")
+		}
+	}
+
+	return nil
 }
 
-// GetAvailableSlots generates available time slots for an expert on a specific date
-func GetAvailableSlots(expertID uint, date string) ([]TimeSlot, error) {
-	db := database.GetDB()
+// defaultSessionMinutes is the session length of experts that haven't chosen one
+const defaultSessionMinutes = 30
 
-	// Parse the date to get day of week
-	parsedDate, err := time.Parse("2006-01-02", date)
+// parseClock parses a strict "HH:MM" clock time into minutes after midnight
+func parseClock(clock string) (int, error) {
+	if !utils.IsClockTime(clock) {
+		return 0, invalidInput("invalid clock time %q, expected HH:MM", clock)
+	}
+	parsed, err := time.Parse("15:04", clock)
 	if err != nil {
-		return nil, errors.New("invalid date format, expected YYYY-MM-DD")
+		return 0, err
 	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
 
-	dayOfWeek := int(parsedDate.Weekday())
+// combineDateAndClock returns the instant on the given date at the "HH:MM" clock time
+func combineDateAndClock(date time.Time, clock string) (time.Time, error) {
+	minutes, err := parseClock(clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), minutes/60, minutes%60, 0, 0, date.Location()), nil
+}
 
-	// Get all availability slots for this expert on this day of week
-	var availabilitySlots []models.AvailabilitySlot
-	if err := db.Where("expert_id = ? AND day_of_week = ?", expertID, dayOfWeek).
-		Order("start_time ASC").
-		Find(&availabilitySlots).Error; err != nil {
+// getExpertByID loads an expert, translating a missing row into a readable error
+func getExpertByID(db *gorm.DB, expertID uint) (*models.Expert, error) {
+	var expert models.Expert
+	if err := db.First(&expert, expertID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalidInput("expert not found")
+		}
 		return nil, err
 	}
+	return &expert, nil
+}
+
+// sessionType describes the sessions of an offering or the expert's default session
+type sessionType struct {
+	Duration   time.Duration
+	OfferingID *uint // nil for the expert's default session
+	Capacity   int   // Seats per session, 1 for individual sessions
+	// Pricing of the offering, see quoteSession
+	Title           string
+	PriceMinor      *int64
+	DiscountPercent int
+}
+
+// key returns the key shared by the bookings and holds of the group session starting
+// at startAt, or nil for individual sessions
+func (s sessionType) key(startAt time.Time) *string {
+	if s.Capacity <= 1 || s.OfferingID == nil {
+		return nil
+	}
+	key := fmt.Sprintf("offering:%d:%d", *s.OfferingID, startAt.Unix())
+	return &key
+}
+
+// resolveSession returns the sessions of the expert's offering, or the expert's
+// default session when offeringID is 0
+func resolveSession(db *gorm.DB, expert *models.Expert, offeringID uint) (sessionType, error) {
+	if offeringID != 0 {
+		var offering models.Offering
+		if err := db.Where("id = ? AND expert_id = ?", offeringID, expert.ID).First(&offering).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return sessionType{}, invalidInput("offering not found")
+			}
+			return sessionType{}, err
+		}
+		return sessionOf(&offering), nil
+	}
+
+	minutes := expert.DefaultSessionMinutes
+	if minutes <= 0 {
+		minutes = defaultSessionMinutes
+	}
+	return sessionType{Duration: time.Duration(minutes) * time.Minute, Capacity: 1}, nil
+}
+
+// sessionOf returns the sessions of an offering
+func sessionOf(offering *models.Offering) sessionType {
+	capacity := offering.Capacity
+	if capacity < 1 {
+		capacity = 1
+	}
+	return sessionType{
+		Duration:        time.Duration(offering.DurationMinutes) * time.Minute,
+		OfferingID:      &offering.ID,
+		Capacity:        capacity,
+		Title:           offering.Title,
+		PriceMinor:      offering.PriceMinor,
+		DiscountPercent: offering.DiscountPercent,
+	}
+}
+
+// storedSession returns the kind of session of an existing booking or hold. The
+// offering is looked up even if it was withdrawn in the meantime.
+func storedSession(db *gorm.DB, offeringID *uint, duration time.Duration) (sessionType, error) {
+	if offeringID == nil {
+		return sessionType{Duration: duration, Capacity: 1}, nil
+	}
+
+	var offering models.Offering
+	if err := db.Unscoped().First(&offering, *offeringID).Error; err != nil {
+		return sessionType{}, err
+	}
+
+	session := sessionOf(&offering)
+	session.Duration = duration
+	return session, nil
+}
+
+// busyRange is a time range in which the expert cannot take another session, except
+// for further seats of the same group session
+type busyRange struct {
+	Start      time.Time
+	End        time.Time
+	SessionKey string // Group session the range holds a seat in, empty for individual sessions
+}
+
+func busyRangeOf(start, end time.Time, sessionKey *string) busyRange {
+	b := busyRange{Start: start, End: end}
+	if sessionKey != nil {
+		b.SessionKey = *sessionKey
+	}
+	return b
+}
+
+// maxSessionLength is the longest a booking lasts. Bookings overlapping a range start
+// less than this before it, which bounds start_at from below in overlap lookups.
+const maxSessionLength = maxSessionMinutes * time.Minute
 
-	// If no availability slots, return empty array
-	if len(availabilitySlots) == 0 {
-		return []TimeSlot{}, nil
+// loadBusyRanges returns the time occupied by the expert's active bookings and by
+// active slot holds in [from, to)
+func loadBusyRanges(db *gorm.DB, expertID uint, from, to time.Time) ([]busyRange, error) {
+	busy, err := loadBusyRangesByExpert(db, []uint{expertID}, from, to)
+	if err != nil {
+		return nil, err
 	}
+	return busy[expertID], nil
+}
 
-	// Get all bookings for this expert on this date
+// loadBusyRangesByExpert is loadBusyRanges for several experts at once, with one query
+// for bookings and one for holds
+func loadBusyRangesByExpert(db *gorm.DB, expertIDs []uint, from, to time.Time) (map[uint][]busyRange, error) {
+	// Only bookings overlapping the range matter. Bounding start_at on both sides lets
+	// idx_bookings_expert_start read just the bookings near the range, however many
+	// bookings the expert has had.
 	var bookings []models.Booking
-	if err := db.Preload("Slot").
-		Where("expert_id = ? AND status != ?", expertID, models.BookingStatusCancelled).
+	if err := db.Select("expert_id", "start_at", "end_at", "session_key").
+		Where("expert_id IN ? AND status NOT IN ? AND start_at > ? AND start_at < ? AND end_at > ?",
+			expertIDs, models.ReleasedBookingStatuses, from.Add(-maxSessionLength), to, from).
 		Find(&bookings).Error; err != nil {
 		return nil, err
 	}
 
-	// Create a map of booked times for quick lookup
-	bookedTimes := make(map[string]bool)
+	// Active holds keep their time reserved for the user checking out
+	var holds []models.SlotHold
+	if err := db.Select("expert_id", "start_at", "end_at", "session_key").
+		Where("expert_id IN ? AND booking_id IS NULL AND expires_at > ? AND start_at < ? AND end_at > ?",
+			expertIDs, time.Now(), to, from).
+		Find(&holds).Error; err != nil {
+		return nil, err
+	}
+
+	busy := make(map[uint][]busyRange, len(expertIDs))
 	for _, booking := range bookings {
-		// For each booking, mark the time slot as booked
-		bookedTimes[booking.Slot.StartTime] = true
+		busy[booking.ExpertID] = append(busy[booking.ExpertID], busyRangeOf(booking.StartAt, booking.EndAt, booking.SessionKey))
+	}
+	for _, hold := range holds {
+		busy[hold.ExpertID] = append(busy[hold.ExpertID], busyRangeOf(hold.StartAt, hold.EndAt, hold.SessionKey))
 	}
 
-	// Generate time slots
-	var timeSlots []TimeSlot
-	slotDuration := 30 // 30 minutes per slot
+	return busy, nil
+}
 
-	for _, availSlot := range availabilitySlots {
-		// Parse start and end times
-		startTime, err := time.Parse("15:04", availSlot.StartTime)
-		if err != nil {
-			continue
-		}
-		endTime, err := time.Parse("15:04", availSlot.EndTime)
-		if err != nil {
-			continue
+// TimeSlot represents a bookable time slot
+type TimeSlot struct {
+	Time           string    `json:"time"`     // "HH:MM" in the requested time zone
+	EndTime        string    `json:"end_time"` // "HH:MM" in the requested time zone
+	StartAt        time.Time `json:"start_at"` // RFC3339 instant in the requested time zone
+	EndAt          time.Time `json:"end_at"`   // RFC3339 instant in the requested time zone
+	Capacity       int       `json:"capacity"`
+	SeatsRemaining int       `json:"seats_remaining"`
+	Available      bool      `json:"available"` // Whether any seat remains
+	ID             uint      `json:"id"`
+}
+
+// maxAvailabilityRangeDays caps the number of days GetAvailabilityRange answers at once
+const maxAvailabilityRangeDays = 62
+
+// DayAvailability lists the slots of one day in the requested time zone
+type DayAvailability struct {
+	Date            string     `json:"date"` // Format: "YYYY-MM-DD"
+	HasAvailability bool       `json:"has_availability"`
+	Slots           []TimeSlot `json:"slots,omitempty"` // Omitted for days without slots and in summaries
+}
+
+// GetAvailableSlots generates available time slots for an expert on a specific date,
+// from the weekly rules and the date-specific overrides.
+// The date and the returned times are in the tz time zone, or the expert's own time
+// zone when tz is empty. Slots last as long as the given offering, or the expert's
+// default session when offeringID is 0, and report the seats left of group sessions.
+func GetAvailableSlots(expertID uint, date string, offeringID uint, tz string) ([]TimeSlot, error) {
+	days, err := GetAvailabilityRange(expertID, date, date, offeringID, tz)
+	if err != nil {
+		return nil, err
+	}
+	return days[0].Slots, nil
+}
+
+// GetAvailabilityRange generates the slots of every day from the from date to the to
+// date inclusive, like GetAvailableSlots does for a single date. The bookings and holds
+// of the whole range are loaded at once.
+func GetAvailabilityRange(expertID uint, from, to string, offeringID uint, tz string) ([]DayAvailability, error) {
+	db := database.GetDB()
+
+	expert, err := getExpertByID(db, expertID)
+	if err != nil {
+		return nil, err
+	}
+	rules := rulesFor(expert)
+
+	viewerLoc := expertLocation(expert)
+	if tz != "" {
+		if viewerLoc, err = loadLocation(tz); err != nil {
+			return nil, err
 		}
+	}
 
-		// Generate slots in 30-minute intervals
-		currentTime := startTime
-		for currentTime.Before(endTime) {
-			timeStr := currentTime.Format("15:04")
+	// The requested days in the viewer's time zone
+	rangeStart, err := time.ParseInLocation("2006-01-02", from, viewerLoc)
+	if err != nil {
+		return nil, invalidInput("invalid date format, expected YYYY-MM-DD")
+	}
+	lastDay, err := time.ParseInLocation("2006-01-02", to, viewerLoc)
+	if err != nil {
+		return nil, invalidInput("invalid date format, expected YYYY-MM-DD")
+	}
+	if lastDay.Before(rangeStart) {
+		return nil, invalidInput("the end of the range must not be before its start")
+	}
+	rangeEnd := lastDay.AddDate(0, 0, 1)
+	if rangeEnd.After(rangeStart.AddDate(0, 0, maxAvailabilityRangeDays)) {
+		return nil, invalidInput("a range may span at most %d days", maxAvailabilityRangeDays)
+	}
 
-			// Check if this time is booked
-			isAvailable := !bookedTimes[timeStr]
+	session, err := resolveSession(db, expert, offeringID)
+	if err != nil {
+		return nil, err
+	}
+
+	windows, err := loadExpertWindows(db, expert, rangeStart, rangeEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	// Bookings just outside the range can still reach into it through their buffers
+	var busy []busyRange
+	if len(windows) > 0 {
+		margin := rules.maxBuffer()
+		if busy, err = loadBusyRanges(db, expertID, rangeStart.Add(-margin), rangeEnd.Add(margin)); err != nil {
+			return nil, err
+		}
+	}
+
+	generator := slotGenerator{
+		Windows: windows,
+		Busy:    busy,
+		Rules:   rules,
+		Session: session,
+		Now:     time.Now(),
+	}
 
-			timeSlots = append(timeSlots, TimeSlot{
-				Time:      timeStr,
-				Available: isAvailable,
-				ID:        availSlot.ID,
-			})
+	days := []DayAvailability{}
+	for dayStart := rangeStart; dayStart.Before(rangeEnd); dayStart = dayStart.AddDate(0, 0, 1) {
+		slots := generator.slots(dayStart, dayStart.AddDate(0, 0, 1), viewerLoc)
 
-			// Move to next slot
-			currentTime = currentTime.Add(time.Duration(slotDuration) * time.Minute)
+		day := DayAvailability{Date: dayStart.Format("2006-01-02"), Slots: slots}
+		for _, slot := range slots {
+			if slot.Available {
+				day.HasAvailability = true
+				break
+			}
 		}
+		days = append(days, day)
 	}
 
-	return timeSlots, nil
+	return days, nil
 }
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// BenchmarkLoadBusyRangesByExpert loads a week of busy time for experts with a growing
+// booking history. The time per query should stay flat as the history grows.
+func BenchmarkLoadBusyRangesByExpert(b *testing.B) {
+	db := testDB(b)
+
+	from := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	to := from.AddDate(0, 0, 7)
+
+	for _, history := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("history=%d", history), func(b *testing.B) {
+			expert := seedExpert(b, db, 0)
+			user := seedUser(b, db, models.RoleUser)
+
+			// Hourly completed sessions before the range and one session on each day in it
+			bookings := make([]models.Booking, 0, history+7)
+			for i := 1; i <= history; i++ {
+				startAt := from.Add(-time.Duration(i) * time.Hour)
+				bookings = append(bookings, models.Booking{
+					UserID:   user.ID,
+					ExpertID: expert.ID,
+					StartAt:  startAt,
+					EndAt:    startAt.Add(30 * time.Minute),
+					Status:   models.BookingStatusCompleted,
+				})
+			}
+			for day := 0; day < 7; day++ {
+				startAt := from.AddDate(0, 0, day).Add(10 * time.Hour)
+				bookings = append(bookings, models.Booking{
+					UserID:   user.ID,
+					ExpertID: expert.ID,
+					StartAt:  startAt,
+					EndAt:    startAt.Add(30 * time.Minute),
+					Status:   models.BookingStatusConfirmed,
+				})
+			}
+			if err := db.Omit(clause.Associations).CreateInBatches(&bookings, 1000).Error; err != nil {
+				b.Fatalf("create bookings: %v", err)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				busy, err := loadBusyRangesByExpert(db, []uint{expert.ID}, from, to)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(busy[expert.ID]) != 7 {
+					b.Fatalf("got %d busy ranges, want 7", len(busy[expert.ID]))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type BookingAction string
+
+const (
+	BookingActionCancel     BookingAction = "cancel"
+	BookingActionReschedule BookingAction = "reschedule"
+	BookingActionComplete   BookingAction = "complete"
+	BookingActionNoShow     BookingAction = "no_show"
+	BookingActionAccept     BookingAction = "accept"
+	BookingActionDecline    BookingAction = "decline"
+)
+
+// bookingTransitionRule describes which statuses an action may start from, the status
+// it leads to and who may perform it
+type bookingTransitionRule struct {
+	From []models.BookingStatus
+	To   models.BookingStatus // Empty keeps the current status
+	// Actors in order of preference when the caller plays several roles on the booking
+	Actors []models.ActorRole
+	// RequiresStarted only allows the action once the session has begun
+	RequiresStarted bool
+}
+
+var bookingTransitions = map[BookingAction]bookingTransitionRule{
+	BookingActionCancel: {
+		From:   []models.BookingStatus{models.BookingStatusAwaitingPayment, models.BookingStatusPending, models.BookingStatusConfirmed},
+		To:     models.BookingStatusCancelled,
+		Actors: []models.ActorRole{models.ActorUser, models.ActorExpert, models.ActorAdmin},
+	},
+	BookingActionReschedule: {
+		From:   []models.BookingStatus{models.BookingStatusPending, models.BookingStatusConfirmed},
+		Actors: []models.ActorRole{models.ActorUser, models.ActorExpert, models.ActorAdmin},
+	},
+	BookingActionComplete: {
+		From:            []models.BookingStatus{models.BookingStatusConfirmed},
+		To:              models.BookingStatusCompleted,
+		Actors:          []models.ActorRole{models.ActorExpert, models.ActorAdmin},
+		RequiresStarted: true,
+	},
+	BookingActionNoShow: {
+		From:            []models.BookingStatus{models.BookingStatusConfirmed},
+		To:              models.BookingStatusNoShow,
+		Actors:          []models.ActorRole{models.ActorExpert, models.ActorAdmin},
+		RequiresStarted: true,
+	},
+	BookingActionAccept: {
+		From:   []models.BookingStatus{models.BookingStatusPending},
+		To:     models.BookingStatusConfirmed,
+		Actors: []models.ActorRole{models.ActorExpert, models.ActorAdmin},
+	},
+	BookingActionDecline: {
+		From:   []models.BookingStatus{models.BookingStatusPending},
+		To:     models.BookingStatusDeclined,
+		Actors: []models.ActorRole{models.ActorExpert, models.ActorAdmin},
+	},
+}
+
+// CancelBooking cancels a booking that is awaiting payment, pending or confirmed,
+// refunds its payment under the expert's cancellation policy and offers its time to
+// the expert's waitlist
+func CancelBooking(bookingID uint, actor *models.User, reason string) (*models.Booking, error) {
+	tx := database.GetDB().Begin()
+
+	booking, refunds, err := cancelBookingTx(tx, bookingID, actor, reason, time.Now())
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	offerFreedTime(booking.ExpertID, booking.StartAt, booking.EndAt)
+	sendRefunds(refunds)
+
+	return booking, nil
+}
+
+// cancelBookingTx cancels a booking inside the caller's transaction and returns the
+// refunds it owes, to be sent once the transaction commits
+func cancelBookingTx(tx *gorm.DB, bookingID uint, actor *models.User, reason string, cancelledAt time.Time) (*models.Booking, []models.PaymentRefund, error) {
+	var refunds []models.PaymentRefund
+	booking, err := applyBookingActionTx(tx, bookingID, actor, BookingActionCancel, reason, func(tx *gorm.DB, booking *models.Booking) error {
+		if booking.Status == models.BookingStatusAwaitingPayment {
+			// The checkout was abandoned, so its payment won't be collected
+			booking.ExpiresAt = nil
+			return tx.Model(&models.Payment{}).
+				Where("booking_id = ? AND status = ?", booking.ID, models.PaymentInitiated).
+				Update("status", models.PaymentFailed).Error
+		}
+
+		var err error
+		refunds, err = oweCancellationRefunds(tx, booking, actor, cancelledAt, reason)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return booking, refunds, nil
+}
+
+// CompleteBooking marks a confirmed booking whose session has started as completed
+func CompleteBooking(bookingID uint, actor *models.User, reason string) (*models.Booking, error) {
+	return applyBookingAction(bookingID, actor, BookingActionComplete, reason, nil)
+}
+
+// MarkBookingNoShow records that the user did not attend a confirmed booking
+func MarkBookingNoShow(bookingID uint, actor *models.User, reason string) (*models.Booking, error) {
+	return applyBookingAction(bookingID, actor, BookingActionNoShow, reason, nil)
+}
+
+// AcceptBooking confirms a pending booking request before it expires
+func AcceptBooking(bookingID uint, actor *models.User, reason string) (*models.Booking, error) {
+	return applyBookingAction(bookingID, actor, BookingActionAccept, reason, func(tx *gorm.DB, booking *models.Booking) error {
+		if booking.ExpiresAt != nil && !time.Now().Before(*booking.ExpiresAt) {
+			return invalidInput("the booking request has expired")
+		}
+		booking.ExpiresAt = nil
+		return nil
+	})
+}
+
+// DeclineBooking rejects a pending booking request, refunds its payment in full and
+// offers its time to the expert's waitlist
+func DeclineBooking(bookingID uint, actor *models.User, reason string) (*models.Booking, error) {
+	refundReason := "declined by the expert"
+	if reason != "" {
+		refundReason += ": " + reason
+	}
+
+	var refunds []models.PaymentRefund
+	booking, err := applyBookingAction(bookingID, actor, BookingActionDecline, reason, func(tx *gorm.DB, booking *models.Booking) error {
+		booking.ExpiresAt = nil
+
+		var err error
+		refunds, err = oweBookingRefunds(tx, booking.ID, 100, refundReason)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	offerFreedTime(booking.ExpertID, booking.StartAt, booking.EndAt)
+	sendRefunds(refunds)
+
+	return booking, nil
+}
+
+// RescheduleBooking moves a booking to a new start time, keeping its duration
+func RescheduleBooking(bookingID uint, actor *models.User, at SessionTime, reason string) (*models.Booking, error) {
+	db := database.GetDB()
+
+	// A booking never changes expert, so its expert can be read before anything is locked
+	var current models.Booking
+	if err := db.Select("id", "expert_id").First(&current, bookingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookingNotFound
+		}
+		return nil, err
+	}
+
+	tx := db.Begin()
+
+	// Lock the expert row so the new time cannot be taken concurrently. It is locked
+	// before the booking, in the same order as when bookings are created.
+	expert, err := lockExpert(tx, current.ExpertID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	booking, err := applyBookingActionTx(tx, bookingID, actor, BookingActionReschedule, reason, func(tx *gorm.DB, booking *models.Booking) error {
+		startAt, err := resolveSessionStart(expert, at)
+		if err != nil {
+			return err
+		}
+		endAt := startAt.Add(booking.EndAt.Sub(booking.StartAt))
+
+		if err := rulesFor(expert).checkBookingWindow(startAt, time.Now()); err != nil {
+			return err
+		}
+
+		slotID, err := findAvailabilityWindow(tx, expert, startAt, endAt)
+		if err != nil {
+			return err
+		}
+
+		// A group booking moves to the group session at the new time
+		session, err := storedSession(tx, booking.OfferingID, endAt.Sub(startAt))
+		if err != nil {
+			return err
+		}
+		sessionKey := session.key(startAt)
+
+		if err := ensureTimeRangeFree(tx, expert, startAt, endAt, booking.ID, sessionKey); err != nil {
+			return err
+		}
+
+		if err := ensureNoConflictingHold(tx, expert, startAt, endAt, booking.UserID, sessionKey); err != nil {
+			return err
+		}
+
+		if err := ensureSeatAvailable(tx, sessionKey, session.Capacity, booking.UserID, booking.ID); err != nil {
+			return err
+		}
+
+		booking.SessionKey = sessionKey
+		booking.SlotID = slotID
+		booking.StartAt = startAt
+		booking.EndAt = endAt
+		return nil
+	})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrTimeSlotUnavailable
+		}
+		return nil, err
+	}
+
+	return booking, nil
+}
+
+// GetBookingTransitions returns the audit trail of a booking visible to the caller
+func GetBookingTransitions(bookingID uint, actor *models.User) ([]models.BookingTransition, error) {
+	db := database.GetDB()
+
+	var booking models.Booking
+	if err := db.First(&booking, bookingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookingNotFound
+		}
+		return nil, err
+	}
+
+	roles, err := bookingActorRoles(db, &booking, actor)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, ErrBookingNotFound
+	}
+
+	var transitions []models.BookingTransition
+	if err := db.Where("booking_id = ?", bookingID).Order("created_at ASC, id ASC").Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+
+	return transitions, nil
+}
+
+// applyBookingAction runs a state machine transition on a booking inside a
+// transaction and records it in the audit trail. mutate may change the booking
+// before it is saved.
+func applyBookingAction(bookingID uint, actor *models.User, action BookingAction, reason string, mutate func(tx *gorm.DB, booking *models.Booking) error) (*models.Booking, error) {
+	tx := database.GetDB().Begin()
+
+	booking, err := applyBookingActionTx(tx, bookingID, actor, action, reason, mutate)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return booking, nil
+}
+
+// applyBookingActionTx is applyBookingAction inside the caller's transaction
+func applyBookingActionTx(tx *gorm.DB, bookingID uint, actor *models.User, action BookingAction, reason string, mutate func(tx *gorm.DB, booking *models.Booking) error) (*models.Booking, error) {
+	rule := bookingTransitions[action]
+
+	var booking models.Booking
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, bookingID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBookingNotFound
+		}
+		return nil, err
+	}
+
+	roles, err := bookingActorRoles(tx, &booking, actor)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		// Don't reveal bookings that belong to somebody else
+		return nil, ErrBookingNotFound
+	}
+
+	actorRole, ok := pickActorRole(rule.Actors, roles)
+	if !ok {
+		return nil, ErrBookingActionForbidden
+	}
+
+	if !containsStatus(rule.From, booking.Status) {
+		return nil, ErrInvalidBookingTransition
+	}
+
+	if rule.RequiresStarted && time.Now().Before(booking.StartAt) {
+		return nil, invalidInput("the session has not started yet")
+	}
+
+	transition := models.BookingTransition{
+		BookingID:  booking.ID,
+		Action:     string(action),
+		FromStatus: booking.Status,
+		ToStatus:   booking.Status,
+		ActorID:    &actor.ID,
+		ActorRole:  actorRole,
+		Reason:     reason,
+	}
+
+	previousStartAt := booking.StartAt
+	if mutate != nil {
+		if err := mutate(tx, &booking); err != nil {
+			return nil, err
+		}
+	}
+	if !booking.StartAt.Equal(previousStartAt) {
+		transition.FromStartAt = &previousStartAt
+		transition.ToStartAt = &booking.StartAt
+	}
+
+	if rule.To != "" {
+		booking.Status = rule.To
+		transition.ToStatus = rule.To
+	}
+
+	if err := tx.Save(&booking).Error; err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrTimeSlotUnavailable
+		}
+		return nil, err
+	}
+
+	if err := tx.Create(&transition).Error; err != nil {
+		return nil, err
+	}
+
+	return &booking, nil
+}
+
+// bookingActorRoles returns every role the user plays on the booking
+func bookingActorRoles(db *gorm.DB, booking *models.Booking, user *models.User) ([]models.ActorRole, error) {
+	var roles []models.ActorRole
+
+	if booking.UserID == user.ID {
+		roles = append(roles, models.ActorUser)
+	}
+
+	var expert models.Expert
+	err := db.Where("user_id = ?", user.ID).First(&expert).Error
+	if err == nil && expert.ID == booking.ExpertID {
+		roles = append(roles, models.ActorExpert)
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	if user.Role == models.RoleAdmin {
+		roles = append(roles, models.ActorAdmin)
+	}
+
+	return roles, nil
+}
+
+// pickActorRole returns the first allowed role that the caller plays
+func pickActorRole(allowed, roles []models.ActorRole) (models.ActorRole, bool) {
+	for _, candidate := range allowed {
+		for _, role := range roles {
+			if candidate == role {
+				return candidate, true
+			}
+		}
+	}
+	return "", false
+}
+
+func containsStatus(statuses []models.BookingStatus, status models.BookingStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+// bookingRules are the expert's constraints on when sessions may be booked
+type bookingRules struct {
+	BufferBefore time.Duration // Free time required before each session
+	BufferAfter  time.Duration // Free time required after each session
+	MinNotice    time.Duration // Sessions must start at least this far in the future
+	MaxAdvance   time.Duration // Sessions must start within this horizon, 0 for no limit
+}
+
+func rulesFor(expert *models.Expert) bookingRules {
+	return bookingRules{
+		BufferBefore: time.Duration(expert.BufferBeforeMinutes) * time.Minute,
+		BufferAfter:  time.Duration(expert.BufferAfterMinutes) * time.Minute,
+		MinNotice:    time.Duration(expert.MinNoticeMinutes) * time.Minute,
+		MaxAdvance:   time.Duration(expert.MaxAdvanceDays) * 24 * time.Hour,
+	}
+}
+
+// maxBuffer is how far around a session other sessions need to be looked at
+func (r bookingRules) maxBuffer() time.Duration {
+	if r.BufferBefore > r.BufferAfter {
+		return r.BufferBefore
+	}
+	return r.BufferAfter
+}
+
+// conflicts reports whether a session at [start, end) clashes with a busy range once
+// buffers are applied: the new session's buffers may not overlap the busy range, and
+// the busy range's buffers may not overlap the new session
+func (r bookingRules) conflicts(b busyRange, start, end time.Time) bool {
+	paddedStart, paddedEnd := start.Add(-r.BufferBefore), end.Add(r.BufferAfter)
+	if paddedStart.Before(b.End) && b.Start.Before(paddedEnd) {
+		return true
+	}
+
+	busyStart, busyEnd := b.Start.Add(-r.BufferBefore), b.End.Add(r.BufferAfter)
+	return start.Before(busyEnd) && busyStart.Before(end)
+}
+
+// seatsLeft returns how many seats of a session at [start, end) are free. Bookings and
+// holds of the same group session, identified by sessionKey, take one seat each; any
+// other clash takes them all.
+func (r bookingRules) seatsLeft(busy []busyRange, start, end time.Time, sessionKey *string, capacity int) int {
+	seats := capacity
+	for _, b := range busy {
+		if sessionKey != nil && b.SessionKey == *sessionKey {
+			seats--
+			continue
+		}
+		if r.conflicts(b, start, end) {
+			return 0
+		}
+	}
+
+	if seats < 0 {
+		return 0
+	}
+	return seats
+}
+
+// withinBookingWindow reports whether a session starting at start respects the
+// minimum notice and maximum advance booking horizon
+func (r bookingRules) withinBookingWindow(start, now time.Time) bool {
+	if start.Before(now.Add(r.MinNotice)) {
+		return false
+	}
+	if r.MaxAdvance > 0 && start.After(now.Add(r.MaxAdvance)) {
+		return false
+	}
+	return true
+}
+
+// checkBookingWindow explains why a session start falls outside the booking window
+func (r bookingRules) checkBookingWindow(start, now time.Time) error {
+	if start.Before(now.Add(r.MinNotice)) {
+		return invalidInput("the session starts too soon, the expert requires more notice")
+	}
+	if r.MaxAdvance > 0 && start.After(now.Add(r.MaxAdvance)) {
+		return invalidInput("the session is too far in the future for this expert")
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+// maxSeriesOccurrences bounds the number of bookings one series may create
+const maxSeriesOccurrences = 52
+
+// SeriesRule describes when the occurrences of a booking series take place. Exactly
+// one of Count and Until bounds the series.
+type SeriesRule struct {
+	Frequency models.SeriesFrequency
+	Count     int    // Number of occurrences
+	Until     string // Last possible date, format "YYYY-MM-DD", inclusive
+}
+
+// SeriesOccurrence reports the outcome of one occurrence of a new series
+type SeriesOccurrence struct {
+	StartAt   time.Time `json:"start_at"`
+	Booked    bool      `json:"booked"`
+	BookingID uint      `json:"booking_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// SeriesResult is the outcome of creating a booking series
+type SeriesResult struct {
+	Series      models.BookingSeries `json:"series"`
+	Occurrences []SeriesOccurrence   `json:"occurrences"`
+}
+
+// SeriesBookingError is returned when none of the occurrences of a series could be
+// booked. It reports why each occurrence failed.
+type SeriesBookingError struct {
+	Occurrences []SeriesOccurrence
+}
+
+func (e *SeriesBookingError) Error() string {
+	return "none of the occurrences of the series could be booked"
+}
+
+// CreateBookingSeries books the expert at the same local time every week or every
+// other week, starting at the given session time. Every occurrence is checked like a
+// single booking; occurrences that conflict are skipped and reported while the others
+// are booked. All bookings are made in one transaction under the expert lock. Only
+// free sessions can be booked as a series.
+func CreateBookingSeries(userID, expertID uint, at SessionTime, offeringID uint, rule SeriesRule) (*SeriesResult, error) {
+	db := database.GetDB()
+
+	expert, err := getExpertByID(db, expertID)
+	if err != nil {
+		return nil, err
+	}
+
+	firstStart, err := resolveSessionStart(expert, at)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := resolveSession(db, expert, offeringID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Series are not paid for, so only free sessions can be booked as one
+	if quoteSession(expert, session, time.Now()).Total.IsPositive() {
+		return nil, ErrPaymentRequired
+	}
+
+	starts, err := seriesOccurrences(firstStart, at.StartTime, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	series := models.BookingSeries{
+		UserID:     userID,
+		ExpertID:   expertID,
+		OfferingID: session.OfferingID,
+		Frequency:  rule.Frequency,
+		FirstDate:  firstStart.Format("2006-01-02"),
+		StartTime:  at.StartTime,
+		TimeZone:   firstStart.Location().String(),
+		Count:      rule.Count,
+		UntilDate:  rule.Until,
+	}
+
+	tx := db.Begin()
+
+	expert, err = lockExpert(tx, expertID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Create(&series).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	result := SeriesResult{Series: series, Occurrences: make([]SeriesOccurrence, len(starts))}
+	booked := 0
+	for i, startAt := range starts {
+		occurrence := SeriesOccurrence{StartAt: startAt}
+
+		// A failed occurrence must not abort the transaction for the others
+		savepoint := fmt.Sprintf("occurrence_%d", i)
+		if err := tx.SavePoint(savepoint).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		booking, err := insertBooking(tx, expert, newBooking{
+			UserID:   userID,
+			ExpertID: expertID,
+			Session:  session,
+			StartAt:  startAt,
+			EndAt:    startAt.Add(session.Duration),
+			SeriesID: &series.ID,
+		})
+		if err != nil {
+			if rollbackErr := tx.RollbackTo(savepoint).Error; rollbackErr != nil {
+				tx.Rollback()
+				return nil, rollbackErr
+			}
+			occurrence.Error = err.Error()
+		} else {
+			occurrence.Booked = true
+			occurrence.BookingID = booking.ID
+			booked++
+		}
+
+		result.Occurrences[i] = occurrence
+	}
+
+	if booked == 0 {
+		tx.Rollback()
+		return nil, &SeriesBookingError{Occurrences: result.Occurrences}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrTimeSlotUnavailable
+		}
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// CancelBookingAndFollowing cancels a booking together with every later occurrence of
+// its series that is still pending or confirmed, in one transaction: either all of
+// them are cancelled or none is. Refunds are sent and the freed times offered to the
+// waitlist once it commits. A one-off booking is cancelled alone.
+func CancelBookingAndFollowing(bookingID uint, actor *models.User, reason string) ([]models.Booking, error) {
+	cancelledAt := time.Now()
+	tx := database.GetDB().Begin()
+
+	// The selected booking goes first so that its permission and status checks decide
+	// whether anything is cancelled
+	first, refunds, err := cancelBookingTx(tx, bookingID, actor, reason, cancelledAt)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	cancelled := []models.Booking{*first}
+	if first.SeriesID != nil {
+		var following []models.Booking
+		if err := tx.Select("id").
+			Where("series_id = ? AND start_at > ? AND status IN ?", *first.SeriesID, first.StartAt,
+				[]models.BookingStatus{models.BookingStatusPending, models.BookingStatusConfirmed}).
+			Order("start_at ASC").
+			Find(&following).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+
+		for _, occurrence := range following {
+			booking, owed, err := cancelBookingTx(tx, occurrence.ID, actor, reason, cancelledAt)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			cancelled = append(cancelled, *booking)
+			refunds = append(refunds, owed...)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	for _, booking := range cancelled {
+		offerFreedTime(booking.ExpertID, booking.StartAt, booking.EndAt)
+	}
+	sendRefunds(refunds)
+
+	return cancelled, nil
+}
+
+// seriesOccurrences returns the start of every occurrence of a series whose first
+// occurrence starts at first. Occurrences keep their local clock time across DST.
+func seriesOccurrences(first time.Time, clock string, rule SeriesRule) ([]time.Time, error) {
+	var stepDays int
+	switch rule.Frequency {
+	case models.SeriesFrequencyWeekly:
+		stepDays = 7
+	case models.SeriesFrequencyBiweekly:
+		stepDays = 14
+	default:
+		return nil, invalidInput("frequency must be either 'weekly' or 'biweekly'")
+	}
+
+	if (rule.Count == 0) == (rule.Until == "") {
+		return nil, invalidInput("exactly one of count and until must be given")
+	}
+	if rule.Count < 0 || rule.Count > maxSeriesOccurrences {
+		return nil, invalidInput("count must be between 1 and %d", maxSeriesOccurrences)
+	}
+
+	var until time.Time
+	if rule.Until != "" {
+		lastDay, err := time.ParseInLocation("2006-01-02", rule.Until, first.Location())
+		if err != nil {
+			return nil, invalidInput("invalid until date, expected YYYY-MM-DD")
+		}
+		until = lastDay.AddDate(0, 0, 1)
+		if !until.After(first) {
+			return nil, invalidInput("until must not be before the first occurrence")
+		}
+	}
+
+	firstDay := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, first.Location())
+	var starts []time.Time
+	for i := 0; ; i++ {
+		startAt, err := combineDateAndClock(firstDay.AddDate(0, 0, i*stepDays), clock)
+		if err != nil {
+			return nil, err
+		}
+
+		if rule.Count > 0 && i >= rule.Count {
+			break
+		}
+		if rule.Until != "" && !startAt.Before(until) {
+			break
+		}
+		if len(starts) == maxSeriesOccurrences {
+			return nil, invalidInput("a series may have at most %d occurrences", maxSeriesOccurrences)
+		}
+
+		starts = append(starts, startAt)
+	}
+
+	return starts, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata" // The DST cases need America/New_York on hosts without a zone database
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+func TestSeriesOccurrences(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Clocks in New York move forward on 2026-03-08, between the first two Mondays
+	first := time.Date(2026, 3, 2, 10, 0, 0, 0, newYork)
+
+	tests := []struct {
+		name  string
+		rule  SeriesRule
+		dates []string
+	}{
+		{
+			name:  "weekly by count",
+			rule:  SeriesRule{Frequency: models.SeriesFrequencyWeekly, Count: 3},
+			dates: []string{"2026-03-02", "2026-03-09", "2026-03-16"},
+		},
+		{
+			name:  "biweekly by count",
+			rule:  SeriesRule{Frequency: models.SeriesFrequencyBiweekly, Count: 3},
+			dates: []string{"2026-03-02", "2026-03-16", "2026-03-30"},
+		},
+		{
+			name:  "weekly until an occurrence",
+			rule:  SeriesRule{Frequency: models.SeriesFrequencyWeekly, Until: "2026-03-16"},
+			dates: []string{"2026-03-02", "2026-03-09", "2026-03-16"},
+		},
+		{
+			name:  "weekly until the day before an occurrence",
+			rule:  SeriesRule{Frequency: models.SeriesFrequencyWeekly, Until: "2026-03-15"},
+			dates: []string{"2026-03-02", "2026-03-09"},
+		},
+		{
+			name:  "biweekly until the first day",
+			rule:  SeriesRule{Frequency: models.SeriesFrequencyBiweekly, Until: "2026-03-02"},
+			dates: []string{"2026-03-02"},
+		},
+		{
+			name:  "the most occurrences",
+			rule:  SeriesRule{Frequency: models.SeriesFrequencyWeekly, Count: maxSeriesOccurrences},
+			dates: nil, // Only the number is checked
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starts, err := seriesOccurrences(first, "10:00", tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := len(tt.dates)
+			if tt.dates == nil {
+				want = tt.rule.Count
+			}
+			if len(starts) != want {
+				t.Fatalf("got %d occurrences, want %d", len(starts), want)
+			}
+
+			for i, startAt := range starts {
+				// Every occurrence starts at 10:00 local time, whether or not DST is in effect
+				if startAt.Location() != newYork || startAt.Hour() != 10 || startAt.Minute() != 0 {
+					t.Errorf("occurrence %d starts at %v, want 10:00 in New York", i, startAt)
+				}
+				if tt.dates != nil && startAt.Format("2006-01-02") != tt.dates[i] {
+					t.Errorf("occurrence %d is on %s, want %s", i, startAt.Format("2006-01-02"), tt.dates[i])
+				}
+			}
+		})
+	}
+
+	// The instants move by an hour less across the DST change
+	starts, err := seriesOccurrences(first, "10:00", SeriesRule{Frequency: models.SeriesFrequencyWeekly, Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gap := starts[1].Sub(starts[0]); gap != 7*24*time.Hour-time.Hour {
+		t.Errorf("occurrences across DST are %v apart, want %v", gap, 7*24*time.Hour-time.Hour)
+	}
+}
+
+func TestSeriesOccurrencesInvalidRules(t *testing.T) {
+	first := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		rule SeriesRule
+	}{
+		{"unknown frequency", SeriesRule{Frequency: "monthly", Count: 3}},
+		{"neither count nor until", SeriesRule{Frequency: models.SeriesFrequencyWeekly}},
+		{"both count and until", SeriesRule{Frequency: models.SeriesFrequencyWeekly, Count: 3, Until: "2026-04-01"}},
+		{"negative count", SeriesRule{Frequency: models.SeriesFrequencyWeekly, Count: -1}},
+		{"too many by count", SeriesRule{Frequency: models.SeriesFrequencyWeekly, Count: maxSeriesOccurrences + 1}},
+		{"too many by until", SeriesRule{Frequency: models.SeriesFrequencyWeekly, Until: "2027-03-01"}},
+		{"malformed until", SeriesRule{Frequency: models.SeriesFrequencyWeekly, Until: "03/16/2026"}},
+		{"until before the first occurrence", SeriesRule{Frequency: models.SeriesFrequencyWeekly, Until: "2026-03-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := seriesOccurrences(first, "10:00", tt.rule)
+			var inputErr *InputError
+			if !errors.As(err, &inputErr) {
+				t.Fatalf("seriesOccurrences() = %v, want an InputError", err)
+			}
+		})
+	}
+}
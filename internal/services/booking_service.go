@@ -2,68 +2,408 @@ package services
 
 import (
 	"errors"
+	"time"
 
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func CreateBooking(userID, expertID, slotID uint) (*models.Booking, error) {
+// SessionTime identifies the start of a session as a local date and clock time
+type SessionTime struct {
+	Date      string // Format: "YYYY-MM-DD"
+	StartTime string // Format: "HH:MM"
+	TimeZone  string // IANA name the date and time are in, empty for the expert's time zone
+}
+
+// CreateBooking books the expert for a session starting at the given time. The
+// session lasts as long as the given offering, or the expert's default session when
+// offeringID is 0, and must be one of the slots GetAvailableSlots offers in the
+// expert's availability. Group sessions take one seat and fail with ErrSessionFull
+// once every seat is taken. Bookings with experts in manual booking mode start out
+// pending. Sessions with a price fail with ErrPaymentRequired; they are booked with
+// Checkout.
+func CreateBooking(userID, expertID uint, at SessionTime, offeringID uint) (*models.Booking, error) {
 	db := database.GetDB()
 
-	// 1. Validate Expert
-	var expert models.Expert
-	if err := db.First(&expert, expertID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("expert not found")
+	expert, err := getExpertByID(db, expertID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Resolve the concrete time range
+	startAt, err := resolveSessionStart(expert, at)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := resolveSession(db, expert, offeringID)
+	if err != nil {
+		return nil, err
+	}
+
+	return createBooking(newBooking{
+		UserID:   userID,
+		ExpertID: expertID,
+		Session:  session,
+		StartAt:  startAt,
+		EndAt:    startAt.Add(session.Duration),
+	})
+}
+
+// newBooking describes a booking to be created by createBooking
+type newBooking struct {
+	UserID   uint
+	ExpertID uint
+	Session  sessionType
+	StartAt  time.Time
+	EndAt    time.Time
+	// HoldID is the user's slot hold converted into the booking, 0 if none
+	HoldID uint
+	// SeriesID is the recurring series the booking is an occurrence of, nil if none
+	SeriesID *uint
+	// AwaitPayment leaves the booking awaiting payment instead of confirming it
+	AwaitPayment bool
+}
+
+// createBooking books the requested time range with the expert
+func createBooking(req newBooking) (*models.Booking, error) {
+	db := database.GetDB()
+
+	// 2. Create Booking (Transaction)
+	tx := db.Begin()
+
+	expert, err := lockExpert(tx, req.ExpertID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	booking, err := insertBooking(tx, expert, req)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrTimeSlotUnavailable
 		}
 		return nil, err
 	}
 
-	// 2. Validate Slot
-	var slot models.AvailabilitySlot
-	if err := db.First(&slot, slotID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("slot not found")
+	return booking, nil
+}
+
+// insertBooking validates and creates a booking inside tx. Call it with the expert
+// row locked.
+func insertBooking(tx *gorm.DB, expert *models.Expert, req newBooking) (*models.Booking, error) {
+	userID, startAt, endAt := req.UserID, req.StartAt, req.EndAt
+
+	// 3. Validate that the range lies within the expert's availability
+	slotID, err := findAvailabilityWindow(tx, expert, startAt, endAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. Enforce the expert's notice and horizon. A converted hold already passed
+	// this check when it was taken.
+	if req.HoldID == 0 {
+		if err := rulesFor(expert).checkBookingWindow(startAt, time.Now()); err != nil {
+			return nil, err
 		}
+	}
+
+	// 5. Make sure nobody else booked or holds the time, apart from the other seats
+	// of a group session
+	sessionKey := req.Session.key(startAt)
+	if err := ensureTimeRangeFree(tx, expert, startAt, endAt, 0, sessionKey); err != nil {
 		return nil, err
 	}
 
-	// TODO: Update booking logic to work with recurring availability slots
-	// The availability model now uses recurring weekly slots, not specific bookable slots
-	// Need to check if there's already a booking for the specific date/time
-	// if slot.IsBooked {
-	// 	return nil, errors.New("slot is already booked")
-	// }
+	if err := ensureNoConflictingHold(tx, expert, startAt, endAt, userID, sessionKey); err != nil {
+		return nil, err
+	}
 
-	if slot.ExpertID != expertID {
-		return nil, errors.New("slot does not belong to the specified expert")
+	// 6. Take a seat of a group session. The expert lock makes the count reliable.
+	if err := ensureSeatAvailable(tx, sessionKey, req.Session.Capacity, userID, 0); err != nil {
+		return nil, err
 	}
 
-	// 3. Create Booking (Transaction)
-	tx := db.Begin()
+	// The booking keeps the price it was made at. Only checkout may book a session
+	// that costs something, so that it is not confirmed before it is paid.
+	quote := quoteSession(expert, req.Session, time.Now())
+	if !req.AwaitPayment && quote.Total.IsPositive() {
+		return nil, ErrPaymentRequired
+	}
 
 	booking := models.Booking{
-		UserID:   userID,
-		ExpertID: expertID,
-		SlotID:   slotID,
-		Status:   models.BookingStatusConfirmed, // Auto-confirming for now
+		UserID:     userID,
+		ExpertID:   expert.ID,
+		SlotID:     slotID,
+		OfferingID: req.Session.OfferingID,
+		SeriesID:   req.SeriesID,
+		StartAt:    startAt,
+		EndAt:      endAt,
+		Status:     models.BookingStatusConfirmed,
+		SessionKey: sessionKey,
+		Quote:      &quote,
+	}
+
+	switch {
+	case req.AwaitPayment:
+		// The time is only held while the user pays
+		booking.Status = models.BookingStatusAwaitingPayment
+		booking.ExpiresAt = expiryBefore(startAt, config.GetConfig().PaymentTTL)
+	case expert.BookingMode == models.BookingModeManual:
+		// Experts using manual approval have to accept the request before it expires
+		booking.Status = models.BookingStatusPending
+		booking.ExpiresAt = pendingExpiry(startAt)
 	}
 
 	if err := tx.Create(&booking).Error; err != nil {
-		tx.Rollback()
+		// The exclusion constraint is the last line of defence against double booking
+		if isExclusionViolation(err) {
+			return nil, ErrTimeSlotUnavailable
+		}
 		return nil, err
 	}
 
-	// TODO: Update slot booking status logic for recurring slots
-	// if err := tx.Model(&slot).Update("is_booked", true).Error; err != nil {
-	// 	tx.Rollback()
-	// 	return nil, err
-	// }
+	if req.HoldID != 0 {
+		if err := consumeSlotHold(tx, req.HoldID, booking.ID); err != nil {
+			return nil, err
+		}
+	}
 
-	if err := tx.Commit().Error; err != nil {
+	if err := claimWaitlistEntries(tx, &booking, req.HoldID); err != nil {
+		return nil, err
+	}
+
+	transition := models.BookingTransition{
+		BookingID: booking.ID,
+		Action:    "create",
+		ToStatus:  booking.Status,
+		ActorID:   &userID,
+		ActorRole: models.ActorUser,
+	}
+	if err := tx.Create(&transition).Error; err != nil {
 		return nil, err
 	}
 
 	return &booking, nil
 }
+
+// lockExpert loads the expert row FOR UPDATE so that concurrent bookings and holds
+// for the same expert are serialized
+func lockExpert(tx *gorm.DB, expertID uint) (*models.Expert, error) {
+	var expert models.Expert
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&expert, expertID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, invalidInput("expert not found")
+		}
+		return nil, err
+	}
+	return &expert, nil
+}
+
+// pendingExpiry returns when a pending booking starting at startAt expires: after the
+// configured approval window, but never later than the session itself
+func pendingExpiry(startAt time.Time) *time.Time {
+	return expiryBefore(startAt, config.GetConfig().PendingBookingTTL)
+}
+
+// expiryBefore returns the time ttl from now, capped at startAt
+func expiryBefore(startAt time.Time, ttl time.Duration) *time.Time {
+	expiresAt := time.Now().Add(ttl)
+	if startAt.Before(expiresAt) {
+		expiresAt = startAt
+	}
+	return &expiresAt
+}
+
+// resolveSessionStart turns a session time into an instant, reading it in the
+// expert's time zone unless another one is given, and rejects times in the past
+func resolveSessionStart(expert *models.Expert, at SessionTime) (time.Time, error) {
+	loc := expertLocation(expert)
+	if at.TimeZone != "" {
+		var err error
+		if loc, err = loadLocation(at.TimeZone); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	parsedDate, err := time.ParseInLocation("2006-01-02", at.Date, loc)
+	if err != nil {
+		return time.Time{}, invalidInput("invalid date format, expected YYYY-MM-DD")
+	}
+
+	startAt, err := combineDateAndClock(parsedDate, at.StartTime)
+	if err != nil {
+		return time.Time{}, invalidInput("invalid start time format, expected HH:MM")
+	}
+
+	if startAt.Before(time.Now()) {
+		return time.Time{}, invalidInput("cannot book a time in the past")
+	}
+
+	return startAt, nil
+}
+
+// ensureTimeRangeFree returns ErrTimeSlotUnavailable when an active booking of the
+// expert, other than excludeBookingID, clashes with [startAt, endAt) once the expert's
+// buffers are applied. Bookings of the group session sessionKey don't clash. Call it
+// with the expert row locked.
+func ensureTimeRangeFree(tx *gorm.DB, expert *models.Expert, startAt, endAt time.Time, excludeBookingID uint, sessionKey *string) error {
+	rules := rulesFor(expert)
+	margin := rules.maxBuffer()
+
+	from, to := startAt.Add(-margin), endAt.Add(margin)
+	query := tx.Model(&models.Booking{}).Select("start_at", "end_at", "session_key").
+		Where("expert_id = ? AND status NOT IN ? AND start_at > ? AND start_at < ? AND end_at > ?",
+			expert.ID, models.ReleasedBookingStatuses, from.Add(-maxSessionLength), to, from)
+	if excludeBookingID != 0 {
+		query = query.Where("id != ?", excludeBookingID)
+	}
+
+	var bookings []models.Booking
+	if err := query.Find(&bookings).Error; err != nil {
+		return err
+	}
+
+	for _, booking := range bookings {
+		if sessionKey != nil && booking.SessionKey != nil && *booking.SessionKey == *sessionKey {
+			continue
+		}
+		if rules.conflicts(busyRange{Start: booking.StartAt, End: booking.EndAt}, startAt, endAt) {
+			return ErrTimeSlotUnavailable
+		}
+	}
+
+	return nil
+}
+
+// ensureSeatAvailable returns ErrSessionFull when the active bookings and other users'
+// active holds of the group session fill its capacity, and ErrAlreadyInSession when
+// the user already has a seat in it. Booking excludeBookingID is not counted. Individual
+// sessions, with a nil key, always pass. Call it with the expert row locked.
+func ensureSeatAvailable(tx *gorm.DB, sessionKey *string, capacity int, userID, excludeBookingID uint) error {
+	if sessionKey == nil {
+		return nil
+	}
+
+	var seats []models.Booking
+	if err := tx.Select("id", "user_id").
+		Where("session_key = ? AND status NOT IN ? AND id != ?", *sessionKey, models.ReleasedBookingStatuses, excludeBookingID).
+		Find(&seats).Error; err != nil {
+		return err
+	}
+
+	for _, seat := range seats {
+		if seat.UserID == userID {
+			return ErrAlreadyInSession
+		}
+	}
+
+	var held int64
+	if err := tx.Model(&models.SlotHold{}).
+		Where("session_key = ? AND user_id != ? AND booking_id IS NULL AND expires_at > ?", *sessionKey, userID, time.Now()).
+		Count(&held).Error; err != nil {
+		return err
+	}
+
+	if len(seats)+int(held) >= capacity {
+		return ErrSessionFull
+	}
+
+	return nil
+}
+
+// findAvailabilityWindow checks that one of the expert's availability windows fully
+// contains the range [startAt, endAt) and that the range starts on one of the slots
+// GetAvailableSlots offers in it. It returns the weekly rule the window comes from, or
+// nil for an extra window added through an override.
+func findAvailabilityWindow(db *gorm.DB, expert *models.Expert, startAt, endAt time.Time) (*uint, error) {
+	// Windows, extras and blocks next to the range can move where its window starts
+	// once they are merged, so look a day beyond it on both sides
+	windows, err := loadExpertWindows(db, expert, startAt.AddDate(0, 0, -1), endAt.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, window := range windows {
+		if !startAt.Before(window.Start) && !endAt.After(window.End) {
+			if !onSlotGrid(window, startAt, endAt.Sub(startAt)) {
+				return nil, invalidInput("requested time does not start on one of the offered slots")
+			}
+			if window.SlotID == 0 {
+				return nil, nil
+			}
+			slotID := window.SlotID
+			return &slotID, nil
+		}
+	}
+
+	return nil, invalidInput("requested time is outside the expert's availability")
+}
+
+// onSlotGrid reports whether a session of the given duration starting at startAt is
+// one of the sessions slotGenerator cuts back to back from the start of the window
+func onSlotGrid(window availabilityWindow, startAt time.Time, duration time.Duration) bool {
+	if duration <= 0 {
+		return false
+	}
+	return startAt.Sub(window.Start)%duration == 0
+}
+
+// BookingFilter narrows down booking listings. Zero values disable a filter.
+type BookingFilter struct {
+	Statuses []models.BookingStatus
+	From     time.Time // Inclusive lower bound on start_at
+	To       time.Time // Exclusive upper bound on start_at
+}
+
+// GetUserBookings returns a page of the bookings made by a user
+func GetUserBookings(userID uint, filter BookingFilter, page, limit int) ([]models.Booking, int64, error) {
+	db := database.GetDB()
+	return listBookings(db.Where("user_id = ?", userID), "Expert.User", filter, page, limit)
+}
+
+// GetExpertBookings returns a page of the bookings made with an expert
+func GetExpertBookings(expertID uint, filter BookingFilter, page, limit int) ([]models.Booking, int64, error) {
+	db := database.GetDB()
+	return listBookings(db.Where("expert_id = ?", expertID), "User", filter, page, limit)
+}
+
+// listBookings filters and paginates a booking query, preloading the given
+// counterpart association and the availability slot
+func listBookings(query *gorm.DB, counterpart string, filter BookingFilter, page, limit int) ([]models.Booking, int64, error) {
+	query = query.Model(&models.Booking{})
+
+	if len(filter.Statuses) > 0 {
+		query = query.Where("status IN ?", filter.Statuses)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("start_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("start_at < ?", filter.To)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var bookings []models.Booking
+	offset := (page - 1) * limit
+	if err := query.Preload(counterpart).Preload("Slot").
+		Order("start_at ASC, id ASC").
+		Offset(offset).Limit(limit).
+		Find(&bookings).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return bookings, total, nil
+}
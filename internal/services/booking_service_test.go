@@ -0,0 +1,209 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/payments"
+	"gorm.io/gorm"
+)
+
+// testDB connects to the Postgres database in TEST_DB_URL and migrates it like at
+// startup. Tests that need a database are skipped when it is not set. The data they
+// create is left behind, so point it at a throwaway database.
+func testDB(tb testing.TB) *gorm.DB {
+	tb.Helper()
+
+	dsn := os.Getenv("TEST_DB_URL")
+	if dsn == "" {
+		tb.Skip("TEST_DB_URL is not set")
+	}
+
+	if database.DB == nil {
+		database.Connect(&config.Config{DBDriver: "postgres", DBUrl: dsn})
+	}
+	return database.DB
+}
+
+// seeded numbers the users tests create, to keep their emails unique
+var seeded atomic.Int64
+
+// seedUser creates a user with a unique email
+func seedUser(tb testing.TB, db *gorm.DB, role models.UserRole) *models.User {
+	tb.Helper()
+
+	user := models.User{
+		Name:  "Test",
+		Email: fmt.Sprintf("test-%d-%d@example.com", time.Now().UnixNano(), seeded.Add(1)),
+		Role:  role,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		tb.Fatalf("create user: %v", err)
+	}
+	return &user
+}
+
+// seedExpert creates an expert in UTC charging rateMinor cents an hour who is
+// available from 09:00 to 17:00 every day
+func seedExpert(tb testing.TB, db *gorm.DB, rateMinor int64) *models.Expert {
+	tb.Helper()
+
+	user := seedUser(tb, db, models.RoleExpert)
+	expert := models.Expert{
+		UserID:                user.ID,
+		HourlyRate:            models.Money{Minor: rateMinor, Currency: "USD"},
+		BookingMode:           models.BookingModeInstant,
+		DefaultSessionMinutes: 30,
+		TimeZone:              "UTC",
+	}
+	if err := db.Create(&expert).Error; err != nil {
+		tb.Fatalf("create expert: %v", err)
+	}
+
+	for day := 0; day < 7; day++ {
+		slot := models.AvailabilitySlot{ExpertID: expert.ID, DayOfWeek: day, StartTime: "09:00", EndTime: "17:00"}
+		if err := db.Create(&slot).Error; err != nil {
+			tb.Fatalf("create availability: %v", err)
+		}
+	}
+	return &expert
+}
+
+func TestCreateBookingConcurrentlyBooksOnce(t *testing.T) {
+	db := testDB(t)
+	expert := seedExpert(t, db, 0)
+
+	const attempts = 10
+	users := make([]*models.User, attempts)
+	for i := range users {
+		users[i] = seedUser(t, db, models.RoleUser)
+	}
+
+	at := SessionTime{
+		Date:      time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02"),
+		StartTime: "10:00",
+		TimeZone:  "UTC",
+	}
+
+	errs := make([]error, attempts)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range users {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, errs[i] = CreateBooking(users[i].ID, expert.ID, at, 0)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	booked := 0
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			booked++
+		case errors.Is(err, ErrTimeSlotUnavailable):
+		default:
+			t.Errorf("attempt %d: unexpected error %v", i, err)
+		}
+	}
+	if booked != 1 {
+		t.Fatalf("booked %d times, want exactly once", booked)
+	}
+
+	var stored int64
+	if err := db.Model(&models.Booking{}).
+		Where("expert_id = ? AND status NOT IN ?", expert.ID, models.ReleasedBookingStatuses).
+		Count(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored != 1 {
+		t.Fatalf("stored %d bookings, want 1", stored)
+	}
+}
+
+func TestCreateBookingWithPriceNeedsCheckout(t *testing.T) {
+	db := testDB(t)
+	expert := seedExpert(t, db, 5000)
+	user := seedUser(t, db, models.RoleUser)
+
+	at := SessionTime{
+		Date:      time.Now().UTC().AddDate(0, 0, 7).Format("2006-01-02"),
+		StartTime: "11:00",
+		TimeZone:  "UTC",
+	}
+
+	if _, err := CreateBooking(user.ID, expert.ID, at, 0); !errors.Is(err, ErrPaymentRequired) {
+		t.Fatalf("CreateBooking() = %v, want ErrPaymentRequired", err)
+	}
+	series := SeriesRule{Frequency: models.SeriesFrequencyWeekly, Count: 2}
+	if _, err := CreateBookingSeries(user.ID, expert.ID, at, 0, series); !errors.Is(err, ErrPaymentRequired) {
+		t.Fatalf("CreateBookingSeries() = %v, want ErrPaymentRequired", err)
+	}
+
+	var booked int64
+	if err := db.Model(&models.Booking{}).Where("expert_id = ?", expert.ID).Count(&booked).Error; err != nil {
+		t.Fatal(err)
+	}
+	if booked != 0 {
+		t.Fatalf("direct booking created %d bookings, want none", booked)
+	}
+
+	// Checkout books the same time, but only until it is paid
+	payments.Register(payments.NewFakeProvider("", time.Minute))
+	t.Setenv("PAYMENT_PROVIDER", "fake")
+	config.LoadConfig()
+	t.Cleanup(func() { config.LoadConfig() })
+
+	result, err := Checkout(user.ID, expert.ID, at, 0)
+	if err != nil {
+		t.Fatalf("Checkout() = %v", err)
+	}
+	if result.Booking.Status != models.BookingStatusAwaitingPayment {
+		t.Fatalf("checkout booking is %s, want %s", result.Booking.Status, models.BookingStatusAwaitingPayment)
+	}
+	if result.Booking.Quote.Subtotal.Minor != 2500 || result.Payment.Amount != result.Booking.Quote.Total {
+		t.Fatalf("checkout charges %v for a subtotal of %v, want the quoted total of 25.00 USD plus tax",
+			result.Payment.Amount, result.Booking.Quote.Subtotal)
+	}
+}
+
+func TestOnSlotGrid(t *testing.T) {
+	window := availabilityWindow{
+		Start: time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC),
+		End:   time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC),
+	}
+	at := func(hour, minute int) time.Time { return time.Date(2026, 3, 2, hour, minute, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		startAt  time.Time
+		duration time.Duration
+		want     bool
+	}{
+		{"start of the window", at(9, 0), 30 * time.Minute, true},
+		{"later slot", at(10, 30), 30 * time.Minute, true},
+		{"between slots", at(10, 7), 30 * time.Minute, false},
+		{"slot of a longer session", at(10, 30), 45 * time.Minute, true},
+		{"slot of a shorter session only", at(10, 0), 45 * time.Minute, false},
+		{"no duration", at(9, 0), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onSlotGrid(window, tt.startAt, tt.duration); got != tt.want {
+				t.Errorf("onSlotGrid(%s, %v) = %v, want %v", tt.startAt.Format("15:04"), tt.duration, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxCancellationTiers bounds the number of tiers in one cancellation policy
+const maxCancellationTiers = 10
+
+// CancellationTier is one tier of a cancellation policy, see models.CancellationPolicyTier
+type CancellationTier struct {
+	MinNoticeMinutes int
+	RefundPercent    int
+}
+
+// GetCancellationPolicy returns the tiers of the expert's cancellation policy, longest
+// notice first. Without tiers users get a full refund whenever they cancel.
+func GetCancellationPolicy(expertID uint) ([]models.CancellationPolicyTier, error) {
+	return loadCancellationPolicy(database.GetDB(), expertID)
+}
+
+// ReplaceCancellationPolicy makes the given tiers the expert's cancellation policy. A
+// user cancelling with at least a tier's notice is refunded the tier's percentage;
+// the tier with the longest notice that applies wins and with none applying nothing
+// is refunded. An empty policy refunds cancellations in full.
+func ReplaceCancellationPolicy(expertID uint, tiers []CancellationTier) ([]models.CancellationPolicyTier, error) {
+	if len(tiers) > maxCancellationTiers {
+		return nil, invalidInput("a cancellation policy may have at most %d tiers", maxCancellationTiers)
+	}
+
+	notices := make(map[int]bool, len(tiers))
+	for _, tier := range tiers {
+		if tier.MinNoticeMinutes < 0 {
+			return nil, invalidInput("minimum notice cannot be negative")
+		}
+		if tier.RefundPercent < 0 || tier.RefundPercent > 100 {
+			return nil, invalidInput("refund must be between 0 and 100 percent")
+		}
+		if notices[tier.MinNoticeMinutes] {
+			return nil, invalidInput("more than one tier has a minimum notice of %d minutes", tier.MinNoticeMinutes)
+		}
+		notices[tier.MinNoticeMinutes] = true
+	}
+
+	db := database.GetDB()
+	tx := db.Begin()
+
+	// Serialize with cancellations reading the policy, like lockExpert does
+	var expert models.Expert
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&expert, expertID).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExpertProfileNotFound
+		}
+		return nil, err
+	}
+
+	if err := tx.Where("expert_id = ?", expertID).Delete(&models.CancellationPolicyTier{}).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	for _, tier := range tiers {
+		if err := tx.Create(&models.CancellationPolicyTier{
+			ExpertID:         expertID,
+			MinNoticeMinutes: tier.MinNoticeMinutes,
+			RefundPercent:    tier.RefundPercent,
+		}).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return loadCancellationPolicy(db, expertID)
+}
+
+func loadCancellationPolicy(db *gorm.DB, expertID uint) ([]models.CancellationPolicyTier, error) {
+	var tiers []models.CancellationPolicyTier
+	if err := db.Where("expert_id = ?", expertID).Order("min_notice_minutes DESC").Find(&tiers).Error; err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// refundPercent returns the refund a policy grants for cancelling notice before the
+// session. Sessions that already started are not refunded, whatever the policy.
+func refundPercent(tiers []models.CancellationPolicyTier, notice time.Duration) int {
+	if notice <= 0 {
+		return 0
+	}
+	if len(tiers) == 0 {
+		return 100
+	}
+
+	sorted := append([]models.CancellationPolicyTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinNoticeMinutes > sorted[j].MinNoticeMinutes })
+
+	for _, tier := range sorted {
+		if notice >= time.Duration(tier.MinNoticeMinutes)*time.Minute {
+			return tier.RefundPercent
+		}
+	}
+	return 0
+}
+
+// cancellationRefundPercent returns the refund for a booking cancelled in role with
+// notice left before the session. Users get what the expert's cancellation policy
+// grants; cancellations by the expert or an admin are refunded in full.
+func cancellationRefundPercent(role models.ActorRole, tiers []models.CancellationPolicyTier, notice time.Duration) int {
+	if role != models.ActorUser {
+		return 100
+	}
+	return refundPercent(tiers, notice)
+}
+
+// oweCancellationRefunds records the refunds of a booking that actor cancels at
+// cancelledAt. It runs in the transaction that cancels the booking.
+func oweCancellationRefunds(tx *gorm.DB, booking *models.Booking, actor *models.User, cancelledAt time.Time, reason string) ([]models.PaymentRefund, error) {
+	// The role the cancellation is made in decides the refund
+	roles, err := bookingActorRoles(tx, booking, actor)
+	if err != nil {
+		return nil, err
+	}
+	role, _ := pickActorRole(bookingTransitions[BookingActionCancel].Actors, roles)
+
+	var tiers []models.CancellationPolicyTier
+	if role == models.ActorUser {
+		if tiers, err = loadCancellationPolicy(tx, booking.ExpertID); err != nil {
+			return nil, err
+		}
+	}
+
+	notice := booking.StartAt.Sub(cancelledAt)
+	percent := cancellationRefundPercent(role, tiers, notice)
+
+	description := fmt.Sprintf("cancelled by the %s", role)
+	if role == models.ActorUser {
+		description = fmt.Sprintf("cancelled by the user %s before the session, %d%% refunded under the cancellation policy",
+			notice.Truncate(time.Minute), percent)
+	}
+	if reason != "" {
+		description += ": " + reason
+	}
+
+	return oweBookingRefunds(tx, booking.ID, percent, description)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+func TestRefundPercent(t *testing.T) {
+	// Full refund a day ahead, half refund two hours ahead, nothing after that. The tiers
+	// are out of order on purpose.
+	tiers := []models.CancellationPolicyTier{
+		{MinNoticeMinutes: 120, RefundPercent: 50},
+		{MinNoticeMinutes: 24 * 60, RefundPercent: 100},
+	}
+
+	tests := []struct {
+		name   string
+		tiers  []models.CancellationPolicyTier
+		notice time.Duration
+		want   int
+	}{
+		{"two days ahead", tiers, 48 * time.Hour, 100},
+		{"exactly a day ahead", tiers, 24 * time.Hour, 100},
+		{"just under a day ahead", tiers, 24*time.Hour - time.Minute, 50},
+		{"exactly two hours ahead", tiers, 2 * time.Hour, 50},
+		{"an hour ahead", tiers, time.Hour, 0},
+		{"after the start", tiers, -time.Hour, 0},
+		{"after the start with a tier needing no notice", []models.CancellationPolicyTier{{MinNoticeMinutes: 0, RefundPercent: 100}}, -time.Minute, 0},
+		{"no policy", nil, time.Hour, 100},
+		{"no policy at the start", nil, 0, 0},
+		{"no policy after the start", nil, -time.Hour, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := refundPercent(tt.tiers, tt.notice); got != tt.want {
+				t.Errorf("refundPercent(%v) = %d, want %d", tt.notice, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancellationRefundPercent(t *testing.T) {
+	tiers := []models.CancellationPolicyTier{{MinNoticeMinutes: 24 * 60, RefundPercent: 100}}
+
+	tests := []struct {
+		role models.ActorRole
+		want int
+	}{
+		{models.ActorUser, 0},
+		{models.ActorExpert, 100},
+		{models.ActorAdmin, 100},
+	}
+
+	for _, tt := range tests {
+		if got := cancellationRefundPercent(tt.role, tiers, time.Hour); got != tt.want {
+			t.Errorf("cancellationRefundPercent(%s) = %d, want %d", tt.role, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrTimeSlotUnavailable is returned when the requested time overlaps another booking
+	ErrTimeSlotUnavailable = errors.New("the requested time is no longer available")
+
+	// ErrSessionFull is returned when every seat of a group session is taken
+	ErrSessionFull = errors.New("the group session is full")
+
+	// ErrAlreadyInSession is returned when a user asks for a second seat in a group session
+	ErrAlreadyInSession = errors.New("you already have a seat in this session")
+
+	// ErrWaitlistEntryNotFound is returned when a waitlist entry does not exist or belongs to somebody else
+	ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+
+	// ErrSlotHoldNotFound is returned when a hold does not exist or belongs to somebody else
+	ErrSlotHoldNotFound = errors.New("slot hold not found")
+
+	// ErrSlotHoldExpired is returned when a hold is converted after it expired or was used
+	ErrSlotHoldExpired = errors.New("the slot hold has expired")
+
+	// ErrAvailabilityNotFound is returned when an availability slot does not exist or belongs to another expert
+	ErrAvailabilityNotFound = errors.New("availability slot not found")
+
+	// ErrExpertProfileNotFound is returned when the user has no expert profile
+	ErrExpertProfileNotFound = errors.New("expert profile not found")
+
+	// ErrBookingNotFound is returned when a booking does not exist or is not visible to the caller
+	ErrBookingNotFound = errors.New("booking not found")
+
+	// ErrBookingActionForbidden is returned when the caller may see a booking but not perform the action
+	ErrBookingActionForbidden = errors.New("you are not allowed to perform this action on the booking")
+
+	// ErrPaymentNotFound is returned when a payment does not exist or belongs to somebody else
+	ErrPaymentNotFound = errors.New("payment not found")
+
+	// ErrPaymentNotCapturable is returned when a payment is captured in a status that doesn't allow it
+	ErrPaymentNotCapturable = errors.New("the payment cannot be captured in its current status")
+
+	// ErrPaymentDeclined is returned when the provider refused to collect a payment
+	ErrPaymentDeclined = errors.New("the payment was declined")
+
+	// ErrPaymentRequired is returned when a session with a price is booked without going through checkout
+	ErrPaymentRequired = errors.New("the session has a price and must be booked through checkout")
+
+	// ErrPaymentEventMismatch is returned for payment events whose amount or currency differs from the payment
+	ErrPaymentEventMismatch = errors.New("the event's amount or currency does not match the payment")
+
+	// ErrCheckoutExpired is returned when a payment completes after its booking lapsed
+	ErrCheckoutExpired = errors.New("the booking expired before its payment completed, the payment was refunded")
+
+	// ErrInvalidBookingTransition is returned when the booking's status does not allow the action
+	ErrInvalidBookingTransition = errors.New("the booking cannot make this transition from its current status")
+)
+
+// InputError is returned when a request is rejected because of what it asked for, such
+// as a date in the wrong format or a time outside the expert's availability, rather than
+// because something failed while serving it
+type InputError struct {
+	Message string
+}
+
+func (e *InputError) Error() string {
+	return e.Message
+}
+
+// invalidInput returns an *InputError with a formatted message
+func invalidInput(format string, args ...interface{}) error {
+	return &InputError{Message: fmt.Sprintf(format, args...)}
+}
+
+// pgExclusionViolation is the Postgres error code raised by EXCLUDE constraints
+const pgExclusionViolation = "23P01"
+
+// isExclusionViolation reports whether err was raised by a Postgres exclusion constraint
+func isExclusionViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == pgExclusionViolation
+}
@@ -3,14 +3,29 @@ package services
 import (
 	"errors"
 
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
 	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
 	"gorm.io/gorm"
 )
 
-func CreateExpertProfile(userID uint, bio, expertise string, hourlyRate float64) (*models.Expert, error) {
+// CreateExpertProfile turns the user into an expert charging hourlyRateMinor, in minor
+// units of currency. An empty currency stands for the configured default.
+func CreateExpertProfile(userID uint, bio, expertise string, hourlyRateMinor int64, currency string) (*models.Expert, error) {
 	db := database.GetDB()
 
+	if hourlyRateMinor <= 0 {
+		return nil, invalidInput("hourly rate must be positive")
+	}
+
+	if currency == "" {
+		currency = config.GetConfig().DefaultCurrency
+	}
+	currency, err := models.NormalizeCurrency(currency)
+	if err != nil {
+		return nil, invalidInput("%v", err)
+	}
+
 	// Check if expert profile already exists
 	var existingExpert models.Expert
 	if err := db.Where("user_id = ?", userID).First(&existingExpert).Error; err == nil {
@@ -23,7 +38,7 @@ func CreateExpertProfile(userID uint, bio, expertise string, hourlyRate float64)
 		UserID:     userID,
 		Bio:        bio,
 		Expertise:  expertise,
-		HourlyRate: hourlyRate,
+		HourlyRate: models.Money{Minor: hourlyRateMinor, Currency: currency},
 		IsVerified: false,
 	}
 
@@ -47,29 +62,117 @@ func GetExpertProfile(userID uint) (*models.Expert, error) {
 	var expert models.Expert
 	if err := db.Preload("User").Where("user_id = ?", userID).First(&expert).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("expert profile not found")
+			return nil, ErrExpertProfileNotFound
 		}
 		return nil, err
 	}
 	return &expert, nil
 }
 
-func UpdateExpertProfile(userID uint, bio, expertise string, hourlyRate float64) (*models.Expert, error) {
+// ExpertProfileUpdate holds the profile fields to change. Zero values leave the
+// current value untouched.
+type ExpertProfileUpdate struct {
+	Bio             string
+	Expertise       string
+	HourlyRateMinor int64  // In minor units of Currency, or of the current currency
+	Currency        string // ISO 4217 code; a new currency needs a new hourly rate
+	BookingMode     models.BookingMode
+	// DefaultSessionMinutes is the session length used when no offering is chosen
+	DefaultSessionMinutes int
+	// Scheduling rules; nil leaves the current value untouched so they can be reset to 0
+	BufferBeforeMinutes *int
+	BufferAfterMinutes  *int
+	MinNoticeMinutes    *int
+	MaxAdvanceDays      *int
+	// TimeZone is the IANA name the expert's availability is written in
+	TimeZone string
+}
+
+func UpdateExpertProfile(userID uint, update ExpertProfileUpdate) (*models.Expert, error) {
 	db := database.GetDB()
 	var expert models.Expert
 
 	if err := db.Where("user_id = ?", userID).First(&expert).Error; err != nil {
-		return nil, errors.New("expert profile not found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrExpertProfileNotFound
+		}
+		return nil, err
 	}
 
-	if bio != "" {
-		expert.Bio = bio
+	if update.Bio != "" {
+		expert.Bio = update.Bio
+	}
+	if update.Expertise != "" {
+		expert.Expertise = update.Expertise
+	}
+	currency := expert.HourlyRate.Currency
+	if update.Currency != "" {
+		var err error
+		if currency, err = models.NormalizeCurrency(update.Currency); err != nil {
+			return nil, invalidInput("%v", err)
+		}
+	}
+	if currency != expert.HourlyRate.Currency {
+		// Amounts in minor units mean something else in another currency
+		if update.HourlyRateMinor <= 0 {
+			return nil, invalidInput("changing the currency needs a new hourly rate in it")
+		}
+		var fixedPrices int64
+		if err := db.Model(&models.Offering{}).Where("expert_id = ? AND price_minor IS NOT NULL", expert.ID).Count(&fixedPrices).Error; err != nil {
+			return nil, err
+		}
+		if fixedPrices > 0 {
+			return nil, invalidInput("remove the fixed prices of your offerings before changing the currency")
+		}
+	}
+	if update.HourlyRateMinor < 0 {
+		return nil, invalidInput("hourly rate must be positive")
+	}
+	if update.HourlyRateMinor > 0 {
+		expert.HourlyRate = models.Money{Minor: update.HourlyRateMinor, Currency: currency}
+	}
+	if update.BookingMode != "" {
+		if update.BookingMode != models.BookingModeInstant && update.BookingMode != models.BookingModeManual {
+			return nil, invalidInput("booking mode must be either 'instant' or 'manual'")
+		}
+		expert.BookingMode = update.BookingMode
+	}
+	if update.DefaultSessionMinutes != 0 {
+		if err := validateSessionMinutes(update.DefaultSessionMinutes); err != nil {
+			return nil, err
+		}
+		expert.DefaultSessionMinutes = update.DefaultSessionMinutes
+	}
+	if update.BufferBeforeMinutes != nil {
+		if *update.BufferBeforeMinutes < 0 || *update.BufferBeforeMinutes > maxSessionMinutes {
+			return nil, invalidInput("buffer before must be between 0 and 480 minutes")
+		}
+		expert.BufferBeforeMinutes = *update.BufferBeforeMinutes
+	}
+	if update.BufferAfterMinutes != nil {
+		if *update.BufferAfterMinutes < 0 || *update.BufferAfterMinutes > maxSessionMinutes {
+			return nil, invalidInput("buffer after must be between 0 and 480 minutes")
+		}
+		expert.BufferAfterMinutes = *update.BufferAfterMinutes
+	}
+	if update.MinNoticeMinutes != nil {
+		if *update.MinNoticeMinutes < 0 {
+			return nil, invalidInput("minimum notice cannot be negative")
+		}
+		expert.MinNoticeMinutes = *update.MinNoticeMinutes
 	}
-	if expertise != "" {
-		expert.Expertise = expertise
+	if update.MaxAdvanceDays != nil {
+		if *update.MaxAdvanceDays < 0 {
+			return nil, invalidInput("maximum advance days cannot be negative")
+		}
+		expert.MaxAdvanceDays = *update.MaxAdvanceDays
 	}
-	if hourlyRate > 0 {
-		expert.HourlyRate = hourlyRate
+
+	if update.TimeZone != "" {
+		if _, err := loadLocation(update.TimeZone); err != nil {
+			return nil, err
+		}
+		expert.TimeZone = update.TimeZone
 	}
 
 	if err := db.Save(&expert).Error; err != nil {
@@ -107,7 +210,7 @@ func GetExpertByCatergoryName(categoryName string) ([]models.Expert, error) {
 func GetExpertById(id uint) (*models.Expert, error) {
 	db := database.GetDB()
 	var expert models.Expert
-	if err := db.Preload("User").Where("id = ?", id).First(&expert).Error; err != nil {
+	if err := db.Preload("User").Preload("Offerings").Where("id = ?", id).First(&expert).Error; err != nil {
 		return nil, err
 	}
 	return &expert, nil
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm/clause"
+)
+
+// StartExpirySweeper releases expired reservations every interval until ctx is done
+func StartExpirySweeper(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				runExpirySweep(now)
+			}
+		}
+	}()
+}
+
+func runExpirySweep(now time.Time) {
+	expired, err := ExpirePendingBookings(now)
+	if err != nil {
+		log.Printf("Failed to expire pending bookings: %v", err)
+	} else if expired > 0 {
+		log.Printf("Expired %d pending bookings", expired)
+	}
+
+	lapsed, err := ExpireWaitlistOffers(now)
+	if err != nil {
+		log.Printf("Failed to expire waitlist offers: %v", err)
+	} else if lapsed > 0 {
+		log.Printf("Passed on %d expired waitlist offers", lapsed)
+	}
+
+	released, err := ExpireSlotHolds(now)
+	if err != nil {
+		log.Printf("Failed to expire slot holds: %v", err)
+	} else if released > 0 {
+		log.Printf("Released %d expired slot holds", released)
+	}
+
+	refunded, err := RetryRefunds(now)
+	if err != nil {
+		log.Printf("Failed to retry refunds: %v", err)
+	} else if refunded > 0 {
+		log.Printf("Made %d refunds that failed before", refunded)
+	}
+
+	purged, err := PurgeIdempotencyKeys(now.Add(-config.GetConfig().IdempotencyKeyTTL))
+	if err != nil {
+		log.Printf("Failed to purge idempotency keys: %v", err)
+	} else if purged > 0 {
+		log.Printf("Purged %d idempotency keys", purged)
+	}
+}
+
+// PurgeIdempotencyKeys deletes stored idempotent responses created before the cutoff
+func PurgeIdempotencyKeys(before time.Time) (int64, error) {
+	db := database.GetDB()
+
+	result := db.Where("created_at < ?", before).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+// expiryReasons explains why bookings of each expiring status lapse
+var expiryReasons = map[models.BookingStatus]string{
+	models.BookingStatusPending:         "expert did not respond in time",
+	models.BookingStatusAwaitingPayment: "payment was not completed in time",
+}
+
+// ExpirePendingBookings moves pending bookings whose approval window has passed and
+// unpaid bookings whose checkout ran out to expired, refunds the payments of the
+// former, fails those of the latter, offers their time to the waitlist and returns
+// how many were expired. The refunds are recorded with the expiry and sent after it.
+func ExpirePendingBookings(now time.Time) (int, error) {
+	db := database.GetDB()
+	tx := db.Begin()
+
+	// Skip rows an expert is accepting or declining right now
+	var bookings []models.Booking
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status IN ? AND expires_at <= ?", []models.BookingStatus{models.BookingStatusPending, models.BookingStatusAwaitingPayment}, now).
+		Find(&bookings).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	var refunds []models.PaymentRefund
+	for _, booking := range bookings {
+		if err := tx.Model(&booking).Updates(map[string]interface{}{
+			"status":     models.BookingStatusExpired,
+			"expires_at": nil,
+		}).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+
+		switch booking.Status {
+		case models.BookingStatusAwaitingPayment:
+			if err := tx.Model(&models.Payment{}).
+				Where("booking_id = ? AND status = ?", booking.ID, models.PaymentInitiated).
+				Update("status", models.PaymentFailed).Error; err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+		case models.BookingStatusPending:
+			// Bookings paid for before the expert had to approve them
+			owed, err := oweBookingRefunds(tx, booking.ID, 100, expiryReasons[booking.Status])
+			if err != nil {
+				tx.Rollback()
+				return 0, err
+			}
+			refunds = append(refunds, owed...)
+		}
+
+		transition := models.BookingTransition{
+			BookingID:  booking.ID,
+			Action:     "expire",
+			FromStatus: booking.Status,
+			ToStatus:   models.BookingStatusExpired,
+			ActorRole:  models.ActorSystem,
+			Reason:     expiryReasons[booking.Status],
+		}
+		if err := tx.Create(&transition).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	for _, booking := range bookings {
+		offerFreedTime(booking.ExpertID, booking.StartAt, booking.EndAt)
+	}
+	sendRefunds(refunds)
+
+	return len(bookings), nil
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"sort"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+// maxNextAvailableDays caps the search window of FindNextAvailable, which defaults to
+// defaultNextAvailableDays
+const (
+	maxNextAvailableDays     = 14
+	defaultNextAvailableDays = 7
+)
+
+// NextAvailableQuery selects the experts and the time window searched by
+// FindNextAvailable. Zero values disable a filter.
+type NextAvailableQuery struct {
+	Category string
+	// Hourly rate bounds in minor units of Currency
+	MinRateMinor int64
+	MaxRateMinor int64
+	// Currency limits the search to experts charging in it. Rate bounds need a
+	// currency and use the configured default when it is empty.
+	Currency string
+	From     string // First day of the window, format "YYYY-MM-DD", defaults to today
+	To       string // Last day of the window, inclusive, defaults to a week from From
+	TimeZone string // IANA name of the dates and of the returned times, defaults to UTC
+	Limit    int
+}
+
+// ExpertAvailability pairs an expert with the earliest session they can take
+type ExpertAvailability struct {
+	Expert   models.Expert `json:"expert"`
+	NextSlot TimeSlot      `json:"next_slot"`
+}
+
+// FindNextAvailable returns the experts of a category ordered by their earliest free
+// default-length session in the query's window. Experts without a free session in the
+// window are left out. Rules, overrides, bookings and holds are loaded for all
+// matching experts at once.
+func FindNextAvailable(query NextAvailableQuery) ([]ExpertAvailability, error) {
+	db := database.GetDB()
+
+	loc, err := loadLocation(query.TimeZone)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	from := time.Date(now.In(loc).Year(), now.In(loc).Month(), now.In(loc).Day(), 0, 0, 0, 0, loc)
+	if query.From != "" {
+		if from, err = time.ParseInLocation("2006-01-02", query.From, loc); err != nil {
+			return nil, invalidInput("invalid from date, expected YYYY-MM-DD")
+		}
+	}
+	to := from.AddDate(0, 0, defaultNextAvailableDays)
+	if query.To != "" {
+		lastDay, err := time.ParseInLocation("2006-01-02", query.To, loc)
+		if err != nil {
+			return nil, invalidInput("invalid to date, expected YYYY-MM-DD")
+		}
+		to = lastDay.AddDate(0, 0, 1)
+	}
+	if !to.After(from) {
+		return nil, invalidInput("the search window must end after it starts")
+	}
+	if to.After(from.AddDate(0, 0, maxNextAvailableDays)) {
+		return nil, invalidInput("the search window may span at most %d days", maxNextAvailableDays)
+	}
+
+	// Nothing before now can be booked
+	if from.Before(now) {
+		from = now
+	}
+	if !to.After(from) {
+		return []ExpertAvailability{}, nil
+	}
+
+	expertQuery := db.Preload("User").Where("expertise = ?", query.Category)
+	currency := query.Currency
+	if currency == "" && (query.MinRateMinor > 0 || query.MaxRateMinor > 0) {
+		currency = config.GetConfig().DefaultCurrency
+	}
+	if currency != "" {
+		if currency, err = models.NormalizeCurrency(currency); err != nil {
+			return nil, invalidInput("%v", err)
+		}
+		expertQuery = expertQuery.Where("hourly_rate_currency = ?", currency)
+	}
+	if query.MinRateMinor > 0 {
+		expertQuery = expertQuery.Where("hourly_rate_minor >= ?", query.MinRateMinor)
+	}
+	if query.MaxRateMinor > 0 {
+		expertQuery = expertQuery.Where("hourly_rate_minor <= ?", query.MaxRateMinor)
+	}
+
+	var experts []models.Expert
+	if err := expertQuery.Find(&experts).Error; err != nil {
+		return nil, err
+	}
+	if len(experts) == 0 {
+		return []ExpertAvailability{}, nil
+	}
+
+	expertIDs := make([]uint, len(experts))
+	margin := time.Duration(0)
+	for i := range experts {
+		expertIDs[i] = experts[i].ID
+		if m := rulesFor(&experts[i]).maxBuffer(); m > margin {
+			margin = m
+		}
+	}
+
+	weekly, err := loadWeeklyRulesByExpert(db, expertIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Local dates differ by at most a day between time zones, so pad the date range
+	// by a day on both ends on top of the day windowsFromRules looks back
+	overrides, err := loadOverridesByExpert(db, expertIDs,
+		from.UTC().AddDate(0, 0, -2).Format("2006-01-02"), to.UTC().AddDate(0, 0, 1).Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+
+	busy, err := loadBusyRangesByExpert(db, expertIDs, from.Add(-margin), to.Add(margin))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []ExpertAvailability{}
+	for i := range experts {
+		expert := &experts[i]
+		if len(weekly[expert.ID]) == 0 && len(overrides[expert.ID]) == 0 {
+			continue
+		}
+
+		expertLoc := expertLocation(expert)
+		session, err := resolveSession(db, expert, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		generator := slotGenerator{
+			Windows: applyOverrides(windowsFromRules(weekly[expert.ID], expertLoc, from, to), overrides[expert.ID], expertLoc, from, to),
+			Busy:    busy[expert.ID],
+			Rules:   rulesFor(expert),
+			Session: session,
+			Now:     now,
+		}
+
+		if slot, ok := generator.firstAvailable(from, to, loc); ok {
+			results = append(results, ExpertAvailability{Expert: *expert, NextSlot: slot})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if !results[i].NextSlot.StartAt.Equal(results[j].NextSlot.StartAt) {
+			return results[i].NextSlot.StartAt.Before(results[j].NextSlot.StartAt)
+		}
+		return results[i].Expert.ID < results[j].Expert.ID
+	})
+
+	if query.Limit > 0 && len(results) > query.Limit {
+		results = results[:query.Limit]
+	}
+
+	return results, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+)
+
+const (
+	minSessionMinutes = 5
+	maxSessionMinutes = 8 * 60
+	maxSessionSeats   = 500
+)
+
+// validateSessionMinutes checks that a session length is within supported bounds
+func validateSessionMinutes(minutes int) error {
+	if minutes < minSessionMinutes || minutes > maxSessionMinutes {
+		return invalidInput("session duration must be between %d and %d minutes", minSessionMinutes, maxSessionMinutes)
+	}
+	return nil
+}
+
+// validateCapacity checks the number of seats of a session
+func validateCapacity(capacity int) error {
+	if capacity < 1 || capacity > maxSessionSeats {
+		return fmt.Errorf("capacity must be between 1 and %d seats", maxSessionSeats)
+	}
+	return nil
+}
+
+// OfferingPricing changes how an offering is priced. Nil fields leave the current
+// value untouched.
+type OfferingPricing struct {
+	// PriceMinor is a fixed price per seat in minor units of the expert's currency,
+	// which replaces the hourly rate. 0 goes back to charging the hourly rate.
+	PriceMinor *int64
+	// DiscountPercent comes off the session price
+	DiscountPercent *int
+}
+
+// apply validates the pricing and sets it on the offering
+func (p OfferingPricing) apply(offering *models.Offering) error {
+	if p.PriceMinor != nil {
+		switch {
+		case *p.PriceMinor < 0:
+			return invalidInput("price cannot be negative")
+		case *p.PriceMinor == 0:
+			offering.PriceMinor = nil
+		default:
+			price := *p.PriceMinor
+			offering.PriceMinor = &price
+		}
+	}
+
+	if p.DiscountPercent != nil {
+		if *p.DiscountPercent < 0 || *p.DiscountPercent > 100 {
+			return invalidInput("discount must be between 0 and 100 percent")
+		}
+		offering.DiscountPercent = *p.DiscountPercent
+	}
+
+	return nil
+}
+
+// CreateOffering adds a session type with its own duration to the expert's profile.
+// A capacity above 1 makes it a group session that several users book together; 0
+// means an individual session.
+func CreateOffering(expertID uint, title string, durationMinutes, capacity int, pricing OfferingPricing) (*models.Offering, error) {
+	db := database.GetDB()
+
+	if err := validateSessionMinutes(durationMinutes); err != nil {
+		return nil, err
+	}
+
+	if capacity == 0 {
+		capacity = 1
+	}
+	if err := validateCapacity(capacity); err != nil {
+		return nil, err
+	}
+
+	offering := models.Offering{
+		ExpertID:        expertID,
+		Title:           title,
+		DurationMinutes: durationMinutes,
+		Capacity:        capacity,
+	}
+
+	if err := pricing.apply(&offering); err != nil {
+		return nil, err
+	}
+
+	if err := db.Create(&offering).Error; err != nil {
+		return nil, err
+	}
+
+	return &offering, nil
+}
+
+// GetOfferingsByExpertID retrieves all offerings of an expert
+func GetOfferingsByExpertID(expertID uint) ([]models.Offering, error) {
+	db := database.GetDB()
+
+	var offerings []models.Offering
+	if err := db.Where("expert_id = ?", expertID).Order("duration_minutes ASC, id ASC").Find(&offerings).Error; err != nil {
+		return nil, err
+	}
+
+	return offerings, nil
+}
+
+// UpdateOffering updates an expert's offering. Zero values leave the current value
+// untouched. Lowering the capacity keeps the seats already booked.
+func UpdateOffering(id, expertID uint, title string, durationMinutes, capacity int, pricing OfferingPricing) (*models.Offering, error) {
+	db := database.GetDB()
+
+	var offering models.Offering
+	if err := db.Where("id = ? AND expert_id = ?", id, expertID).First(&offering).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("offering not found")
+		}
+		return nil, err
+	}
+
+	if title != "" {
+		offering.Title = title
+	}
+	if durationMinutes != 0 {
+		if err := validateSessionMinutes(durationMinutes); err != nil {
+			return nil, err
+		}
+		offering.DurationMinutes = durationMinutes
+	}
+	if capacity != 0 {
+		if err := validateCapacity(capacity); err != nil {
+			return nil, err
+		}
+		offering.Capacity = capacity
+	}
+	if err := pricing.apply(&offering); err != nil {
+		return nil, err
+	}
+
+	if err := db.Save(&offering).Error; err != nil {
+		return nil, err
+	}
+
+	return &offering, nil
+}
+
+// DeleteOffering withdraws an offering. Existing bookings keep their session length.
+func DeleteOffering(id, expertID uint) error {
+	db := database.GetDB()
+
+	result := db.Where("id = ? AND expert_id = ?", id, expertID).Delete(&models.Offering{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("offering not found or you don't have permission to delete it")
+	}
+
+	return nil
+}
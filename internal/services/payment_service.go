@@ -0,0 +1,424 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckoutResult is a booking awaiting payment and the payment collecting its price
+type CheckoutResult struct {
+	Booking      models.Booking `json:"booking"`
+	Payment      models.Payment `json:"payment"`
+	ClientSecret string         `json:"client_secret"` // Lets the client complete the payment with the provider
+}
+
+// PaymentResult is a payment and the booking it pays for
+type PaymentResult struct {
+	Payment models.Payment         `json:"payment"`
+	Booking models.Booking         `json:"booking"`
+	Refunds []models.PaymentRefund `json:"refunds,omitempty"`
+}
+
+// Checkout books the expert like CreateBooking, but the booking only holds its time
+// until the payment TTL runs out. It is confirmed, or sent to the expert for approval
+// in manual booking mode, once its payment completes.
+func Checkout(userID, expertID uint, at SessionTime, offeringID uint) (*CheckoutResult, error) {
+	db := database.GetDB()
+
+	expert, err := getExpertByID(db, expertID)
+	if err != nil {
+		return nil, err
+	}
+
+	startAt, err := resolveSessionStart(expert, at)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := resolveSession(db, expert, offeringID)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkout(newBooking{
+		UserID:       userID,
+		ExpertID:     expertID,
+		Session:      session,
+		StartAt:      startAt,
+		EndAt:        startAt.Add(session.Duration),
+		AwaitPayment: true,
+	})
+}
+
+// CheckoutSlotHold starts paying for the user's active hold, converting it into a
+// booking awaiting payment
+func CheckoutSlotHold(holdID, userID uint) (*CheckoutResult, error) {
+	hold, err := getActiveSlotHold(database.GetDB(), holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := storedSession(database.GetDB(), hold.OfferingID, hold.EndAt.Sub(hold.StartAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return checkout(newBooking{
+		UserID:       userID,
+		ExpertID:     hold.ExpertID,
+		Session:      session,
+		StartAt:      hold.StartAt,
+		EndAt:        hold.EndAt,
+		HoldID:       hold.ID,
+		AwaitPayment: true,
+	})
+}
+
+// checkout creates the booking and its payment, then asks the provider to collect it
+func checkout(req newBooking) (*CheckoutResult, error) {
+	cfg := config.GetConfig()
+
+	provider, err := payments.Get(cfg.PaymentProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	tx := db.Begin()
+
+	expert, err := lockExpert(tx, req.ExpertID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	booking, err := insertBooking(tx, expert, req)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The payment charges the quote snapshotted onto the booking
+	amount := booking.Quote.Total
+	if !amount.IsPositive() {
+		tx.Rollback()
+		return nil, invalidInput("the session is free and can be booked without a checkout")
+	}
+
+	payment := models.Payment{
+		BookingID: booking.ID,
+		UserID:    req.UserID,
+		Amount:    amount,
+		Quote:     booking.Quote,
+		Status:    models.PaymentInitiated,
+		Provider:  provider.Name(),
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		if isExclusionViolation(err) {
+			return nil, ErrTimeSlotUnavailable
+		}
+		return nil, err
+	}
+
+	// Talk to the provider outside the transaction so a slow provider doesn't keep the
+	// expert locked
+	intent, err := provider.CreateIntent(context.Background(), payments.IntentRequest{
+		Amount:    amount.Minor,
+		Currency:  amount.Currency,
+		Reference: strconv.FormatUint(uint64(payment.ID), 10),
+	})
+	if err != nil {
+		if failErr := failPayment(payment.ID, "payment could not be started"); failErr != nil {
+			log.Printf("Failed to release booking %d after a failed checkout: %v", booking.ID, failErr)
+		}
+		return nil, fmt.Errorf("failed to start the payment: %w", err)
+	}
+
+	payment.ProviderIntentID = intent.ID
+	if err := db.Model(&payment).Update("provider_intent_id", intent.ID).Error; err != nil {
+		return nil, err
+	}
+
+	return &CheckoutResult{Booking: *booking, Payment: payment, ClientSecret: intent.ClientSecret}, nil
+}
+
+// GetPayment returns one of the user's payments and its booking
+func GetPayment(paymentID, userID uint) (*PaymentResult, error) {
+	db := database.GetDB()
+
+	payment, err := getUserPayment(db, paymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var booking models.Booking
+	if err := db.First(&booking, payment.BookingID).Error; err != nil {
+		return nil, err
+	}
+
+	var refunds []models.PaymentRefund
+	if err := db.Where("payment_id = ?", payment.ID).Order("created_at ASC, id ASC").Find(&refunds).Error; err != nil {
+		return nil, err
+	}
+
+	return &PaymentResult{Payment: *payment, Booking: booking, Refunds: refunds}, nil
+}
+
+// CapturePayment collects one of the user's payments through its provider and
+// confirms the booking it pays for. Capturing a completed payment again returns it
+// unchanged.
+func CapturePayment(paymentID, userID uint) (*PaymentResult, error) {
+	db := database.GetDB()
+
+	payment, err := getUserPayment(db, paymentID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status == models.PaymentCompleted {
+		return GetPayment(paymentID, userID)
+	}
+	if payment.Status != models.PaymentInitiated || payment.ProviderIntentID == "" {
+		return nil, ErrPaymentNotCapturable
+	}
+
+	provider, err := payments.Get(payment.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	intent, err := provider.Capture(context.Background(), payment.ProviderIntentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to capture the payment: %w", err)
+	}
+
+	switch intent.Status {
+	case payments.IntentSucceeded:
+		return completePayment(payment.ID)
+	case payments.IntentFailed:
+		if err := failPayment(payment.ID, "payment was declined"); err != nil {
+			return nil, err
+		}
+		return nil, ErrPaymentDeclined
+	default:
+		return nil, invalidInput("the payment has not been authorized yet")
+	}
+}
+
+// paymentChange is the outcome of changing a payment in a transaction, with the work
+// left for after the transaction commits
+type paymentChange struct {
+	Payment *models.Payment
+	Booking *models.Booking
+	Lapsed  bool                   // The payment completed after its booking lapsed
+	Freed   bool                   // The booking stopped holding the expert's time
+	Refunds []models.PaymentRefund // Owed refunds to send
+}
+
+// finish offers freed time to the waitlist and sends owed refunds. It must be called
+// once the transaction that made the change committed.
+func (c *paymentChange) finish() {
+	if c.Freed {
+		offerFreedTime(c.Booking.ExpertID, c.Booking.StartAt, c.Booking.EndAt)
+	}
+	sendRefunds(c.Refunds)
+}
+
+// completePayment records that the provider collected a payment and moves its booking
+// on from awaiting payment. When the booking lapsed in the meantime the payment is
+// refunded in full and ErrCheckoutExpired returned. Completing a settled payment again
+// is a no-op.
+func completePayment(paymentID uint) (*PaymentResult, error) {
+	tx := database.GetDB().Begin()
+
+	change, err := completePaymentTx(tx, paymentID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+	change.finish()
+
+	if change.Lapsed {
+		return nil, ErrCheckoutExpired
+	}
+	return &PaymentResult{Payment: *change.Payment, Booking: *change.Booking}, nil
+}
+
+// completePaymentTx is completePayment inside the caller's transaction
+func completePaymentTx(tx *gorm.DB, paymentID uint) (*paymentChange, error) {
+	payment, booking, err := lockPaymentAndBooking(tx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &paymentChange{Payment: payment, Booking: booking}
+
+	switch payment.Status {
+	case models.PaymentCompleted, models.PaymentPartiallyRefunded, models.PaymentRefunded:
+		return change, nil
+	}
+
+	// A failed payment may still complete when the user paid just as the checkout
+	// lapsed; the money is then returned below
+	payment.Status = models.PaymentCompleted
+	if err := tx.Save(payment).Error; err != nil {
+		return nil, err
+	}
+
+	// The money of a lapsed checkout is owed back in full
+	if booking.Status != models.BookingStatusAwaitingPayment {
+		change.Lapsed = true
+		refund, err := owePaymentRefund(tx, payment, payment.Amount, "the booking expired before its payment completed")
+		if err != nil {
+			return nil, err
+		}
+		if refund != nil {
+			change.Refunds = append(change.Refunds, *refund)
+		}
+		return change, nil
+	}
+
+	var expert models.Expert
+	if err := tx.First(&expert, booking.ExpertID).Error; err != nil {
+		return nil, err
+	}
+
+	transition := models.BookingTransition{
+		BookingID:  booking.ID,
+		Action:     "pay",
+		FromStatus: booking.Status,
+		ActorRole:  models.ActorSystem,
+		Reason:     "payment completed",
+	}
+
+	booking.Status = models.BookingStatusConfirmed
+	booking.ExpiresAt = nil
+	if expert.BookingMode == models.BookingModeManual {
+		booking.Status = models.BookingStatusPending
+		booking.ExpiresAt = pendingExpiry(booking.StartAt)
+	}
+	transition.ToStatus = booking.Status
+
+	if err := tx.Save(booking).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// failPayment records that a payment will not be collected and releases its booking
+// if it is still awaiting payment
+func failPayment(paymentID uint, reason string) error {
+	tx := database.GetDB().Begin()
+
+	change, err := failPaymentTx(tx, paymentID, reason)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	change.finish()
+
+	return nil
+}
+
+// failPaymentTx is failPayment inside the caller's transaction
+func failPaymentTx(tx *gorm.DB, paymentID uint, reason string) (*paymentChange, error) {
+	payment, booking, err := lockPaymentAndBooking(tx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &paymentChange{Payment: payment, Booking: booking}
+
+	if payment.Status != models.PaymentInitiated {
+		return change, nil
+	}
+
+	if err := tx.Model(payment).Update("status", models.PaymentFailed).Error; err != nil {
+		return nil, err
+	}
+
+	if booking.Status == models.BookingStatusAwaitingPayment {
+		if err := tx.Model(booking).Updates(map[string]interface{}{
+			"status":     models.BookingStatusExpired,
+			"expires_at": nil,
+		}).Error; err != nil {
+			return nil, err
+		}
+
+		transition := models.BookingTransition{
+			BookingID:  booking.ID,
+			Action:     "payment_failed",
+			FromStatus: models.BookingStatusAwaitingPayment,
+			ToStatus:   models.BookingStatusExpired,
+			ActorRole:  models.ActorSystem,
+			Reason:     reason,
+		}
+		if err := tx.Create(&transition).Error; err != nil {
+			return nil, err
+		}
+		change.Freed = true
+	}
+
+	return change, nil
+}
+
+// lockPaymentAndBooking loads a payment and its booking FOR UPDATE. The booking is
+// locked first like everywhere else bookings change.
+func lockPaymentAndBooking(tx *gorm.DB, paymentID uint) (*models.Payment, *models.Booking, error) {
+	var payment models.Payment
+	if err := tx.First(&payment, paymentID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrPaymentNotFound
+		}
+		return nil, nil, err
+	}
+
+	var booking models.Booking
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&booking, payment.BookingID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return &payment, &booking, nil
+}
+
+func getUserPayment(db *gorm.DB, paymentID, userID uint) (*models.Payment, error) {
+	var payment models.Payment
+	if err := db.Where("id = ? AND user_id = ?", paymentID, userID).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPaymentNotFound
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
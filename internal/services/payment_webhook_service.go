@@ -0,0 +1,96 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HandlePaymentEvent applies a verified webhook event of the named provider to its
+// payment and booking. The event is recorded in the same transaction that applies it,
+// so each event is applied once even when deliveries race, and a delivery that failed
+// is applied again when the provider retries it. Succeeded events that don't pay the
+// payment's amount in its currency are rejected with ErrPaymentEventMismatch.
+func HandlePaymentEvent(provider string, event *payments.Event) error {
+	db := database.GetDB()
+
+	var payment models.Payment
+	if err := db.Where("provider = ? AND provider_intent_id = ?", provider, event.IntentID).First(&payment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The checkout may not have stored the intent yet; the provider retries
+			return ErrPaymentNotFound
+		}
+		return err
+	}
+
+	// A payment is only paid by the amount it asked for
+	if event.Type == payments.EventPaymentSucceeded {
+		if err := checkPaidAmount(&payment, event); err != nil {
+			log.Printf("Rejecting %s payment event %s of payment %d: %v", provider, event.ID, payment.ID, err)
+			return err
+		}
+	}
+
+	tx := db.Begin()
+
+	// Claim the event first. A concurrent delivery of it waits on the unique index and
+	// then finds it claimed; a failure below rolls the claim back.
+	record := models.PaymentEvent{
+		Provider:  provider,
+		EventID:   event.ID,
+		Type:      string(event.Type),
+		PaymentID: &payment.ID,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return nil
+	}
+
+	var change *paymentChange
+	var err error
+	switch event.Type {
+	case payments.EventPaymentSucceeded:
+		// A payment for a lapsed checkout is refunded, which settles the event too
+		change, err = completePaymentTx(tx, payment.ID)
+	case payments.EventPaymentFailed:
+		change, err = failPaymentTx(tx, payment.ID, "payment failed at the provider")
+	case payments.EventRefunded:
+		err = applyProviderRefund(tx, &payment, event)
+	default:
+		log.Printf("Ignoring %s payment event %s of unknown type %q", provider, event.ID, event.Type)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	if change != nil {
+		change.finish()
+	}
+	return nil
+}
+
+// checkPaidAmount returns ErrPaymentEventMismatch unless a succeeded event paid exactly
+// the payment's amount in its currency
+func checkPaidAmount(payment *models.Payment, event *payments.Event) error {
+	if event.Amount != payment.Amount.Minor || !strings.EqualFold(event.Currency, payment.Amount.Currency) {
+		return fmt.Errorf("%w: paid %d %s for %s", ErrPaymentEventMismatch, event.Amount, event.Currency, payment.Amount)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/payments"
+)
+
+func TestCheckPaidAmount(t *testing.T) {
+	payment := &models.Payment{Amount: models.Money{Minor: 2500, Currency: "USD"}}
+
+	tests := []struct {
+		name     string
+		amount   int64
+		currency string
+		wantErr  error
+	}{
+		{"exact amount", 2500, "USD", nil},
+		{"lower case currency", 2500, "usd", nil},
+		{"less than the price", 1, "USD", ErrPaymentEventMismatch},
+		{"more than the price", 2501, "USD", ErrPaymentEventMismatch},
+		{"other currency", 2500, "EUR", ErrPaymentEventMismatch},
+		{"no currency", 2500, "", ErrPaymentEventMismatch},
+		{"no amount", 0, "USD", ErrPaymentEventMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &payments.Event{Type: payments.EventPaymentSucceeded, Amount: tt.amount, Currency: tt.currency}
+			if err := checkPaidAmount(payment, event); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkPaidAmount() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
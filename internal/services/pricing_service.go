@@ -0,0 +1,79 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+// basisPointsPerUnit is 100% expressed in basis points
+const basisPointsPerUnit = 10000
+
+// QuoteBooking prices one seat of the given offering of the expert, or of the expert's
+// default session when offeringID is 0. Bookings made right away get the same quote.
+func QuoteBooking(expertID, offeringID uint) (*models.PriceQuote, error) {
+	db := database.GetDB()
+
+	expert, err := getExpertByID(db, expertID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := resolveSession(db, expert, offeringID)
+	if err != nil {
+		return nil, err
+	}
+
+	quote := quoteSession(expert, session, time.Now())
+	return &quote, nil
+}
+
+// quoteSession itemizes the price of one seat of a session. The offering's fixed price
+// replaces the expert's hourly rate for the session's duration; the offering's
+// discount comes off that, and the configured tax is added on top. Every amount is in
+// the expert's currency.
+func quoteSession(expert *models.Expert, session sessionType, now time.Time) models.PriceQuote {
+	rate := expert.HourlyRate
+	minutes := int64(session.Duration / time.Minute)
+
+	base := rate.Scale(minutes, 60)
+	description := fmt.Sprintf("%d-minute session at %s per hour", minutes, rate)
+	if session.PriceMinor != nil {
+		base = models.Money{Minor: *session.PriceMinor, Currency: rate.Currency}
+		description = session.Title
+	}
+
+	quote := models.PriceQuote{
+		Lines:    []models.QuoteLine{{Kind: models.QuoteLineSession, Description: description, Amount: base}},
+		Subtotal: base,
+		QuotedAt: now,
+	}
+
+	if session.DiscountPercent > 0 {
+		discount := base.Scale(int64(session.DiscountPercent), 100)
+		quote.Lines = append(quote.Lines, models.QuoteLine{
+			Kind:        models.QuoteLineDiscount,
+			Description: fmt.Sprintf("%d%% off", session.DiscountPercent),
+			Amount:      models.Money{Minor: -discount.Minor, Currency: rate.Currency},
+		})
+		quote.Subtotal.Minor -= discount.Minor
+	}
+
+	taxRate := config.GetConfig().TaxRateBasisPoints
+	quote.Tax = quote.Subtotal.Scale(taxRate, basisPointsPerUnit)
+	if taxRate > 0 {
+		percent := strconv.FormatFloat(float64(taxRate)/100, 'f', -1, 64)
+		quote.Lines = append(quote.Lines, models.QuoteLine{
+			Kind:        models.QuoteLineTax,
+			Description: "Tax (" + percent + "%)",
+			Amount:      quote.Tax,
+		})
+	}
+
+	quote.Total = models.Money{Minor: quote.Subtotal.Minor + quote.Tax.Minor, Currency: rate.Currency}
+	return quote
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+func TestQuoteSession(t *testing.T) {
+	price := func(minor int64) *int64 { return &minor }
+
+	tests := []struct {
+		name                 string
+		rate                 models.Money
+		session              sessionType
+		taxBasisPoints       int64
+		subtotal, tax, total int64
+		lines                int
+		titled               bool // The session line is described by the offering title
+	}{
+		{
+			name:     "hourly rate",
+			rate:     models.Money{Minor: 5000, Currency: "USD"},
+			session:  sessionType{Duration: 30 * time.Minute},
+			subtotal: 2500, total: 2500, lines: 1,
+		},
+		{
+			name:     "hourly rate for an odd duration",
+			rate:     models.Money{Minor: 5000, Currency: "USD"},
+			session:  sessionType{Duration: 45 * time.Minute},
+			subtotal: 3750, total: 3750, lines: 1,
+		},
+		{
+			name:     "zero decimal currency",
+			rate:     models.Money{Minor: 6000, Currency: "JPY"},
+			session:  sessionType{Duration: 20 * time.Minute},
+			subtotal: 2000, total: 2000, lines: 1,
+		},
+		{
+			name:     "fixed price replaces the hourly rate",
+			rate:     models.Money{Minor: 5000, Currency: "USD"},
+			session:  sessionType{Duration: 90 * time.Minute, Title: "Portfolio review", PriceMinor: price(1999)},
+			subtotal: 1999, total: 1999, lines: 1,
+			titled: true,
+		},
+		{
+			name:     "discount",
+			rate:     models.Money{Minor: 5000, Currency: "USD"},
+			session:  sessionType{Duration: 30 * time.Minute, DiscountPercent: 10},
+			subtotal: 2250, total: 2250, lines: 2,
+		},
+		{
+			name:           "discount and tax",
+			rate:           models.Money{Minor: 5000, Currency: "USD"},
+			session:        sessionType{Duration: 30 * time.Minute, DiscountPercent: 10},
+			taxBasisPoints: 1900,
+			subtotal:       2250, tax: 428, total: 2678, lines: 3,
+		},
+		{
+			name:           "fixed price with discount and tax",
+			rate:           models.Money{Minor: 5000, Currency: "USD"},
+			session:        sessionType{Duration: 60 * time.Minute, Title: "Mock interview", PriceMinor: price(1999), DiscountPercent: 15},
+			taxBasisPoints: 1900,
+			subtotal:       1699, tax: 323, total: 2022, lines: 3,
+			titled: true,
+		},
+	}
+
+	// Reload the configuration from the restored environment once the test is done
+	t.Cleanup(func() { config.LoadConfig() })
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TAX_RATE_BASIS_POINTS", strconv.FormatInt(tt.taxBasisPoints, 10))
+			config.LoadConfig()
+
+			expert := &models.Expert{HourlyRate: tt.rate}
+			quote := quoteSession(expert, tt.session, now)
+
+			if quote.Subtotal.Minor != tt.subtotal || quote.Tax.Minor != tt.tax || quote.Total.Minor != tt.total {
+				t.Errorf("subtotal, tax, total = %d, %d, %d, want %d, %d, %d",
+					quote.Subtotal.Minor, quote.Tax.Minor, quote.Total.Minor, tt.subtotal, tt.tax, tt.total)
+			}
+			if quote.Total.Currency != tt.rate.Currency {
+				t.Errorf("currency = %s, want %s", quote.Total.Currency, tt.rate.Currency)
+			}
+			if len(quote.Lines) != tt.lines {
+				t.Fatalf("got %d lines, want %d", len(quote.Lines), tt.lines)
+			}
+			if tt.titled && quote.Lines[0].Description != tt.session.Title {
+				t.Errorf("session line = %q, want %q", quote.Lines[0].Description, tt.session.Title)
+			}
+			if !quote.QuotedAt.Equal(now) {
+				t.Errorf("quoted at %v, want %v", quote.QuotedAt, now)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// maxRefundAttempts is how often a refund is sent before it is left for a person to look at
+	maxRefundAttempts = 10
+	// refundRetryDelay keeps the sweeper from resending refunds that are being sent right now
+	refundRetryDelay = time.Minute
+	// refundReferencePrefix prefixes our refund IDs in the references given to providers
+	refundReferencePrefix = "refund_"
+)
+
+// refundedPaymentStatuses are the statuses of payments money can be returned from
+var refundedPaymentStatuses = []models.PaymentStatus{models.PaymentCompleted, models.PaymentPartiallyRefunded}
+
+// oweBookingRefunds records pending refunds of percent of every settled payment of a
+// booking. The payments are locked, so it must run after the booking was locked.
+func oweBookingRefunds(tx *gorm.DB, bookingID uint, percent int, reason string) ([]models.PaymentRefund, error) {
+	if percent <= 0 {
+		return nil, nil
+	}
+
+	var paid []models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("booking_id = ? AND status IN ?", bookingID, refundedPaymentStatuses).
+		Order("id ASC").
+		Find(&paid).Error; err != nil {
+		return nil, err
+	}
+
+	var refunds []models.PaymentRefund
+	for i := range paid {
+		refund, err := owePaymentRefund(tx, &paid[i], paid[i].Amount.Scale(int64(percent), 100), reason)
+		if err != nil {
+			return nil, err
+		}
+		if refund != nil {
+			refunds = append(refunds, *refund)
+		}
+	}
+
+	return refunds, nil
+}
+
+// owePaymentRefund records a pending refund that brings everything refunded of a
+// payment up to amount. Refunds that were not made yet count as refunded, so the same
+// money is never owed twice. The caller must hold the payment's lock. It returns nil
+// when at least amount was refunded before.
+func owePaymentRefund(tx *gorm.DB, payment *models.Payment, amount models.Money, reason string) (*models.PaymentRefund, error) {
+	var owed int64
+	if err := tx.Model(&models.PaymentRefund{}).
+		Where("payment_id = ?", payment.ID).
+		Select("COALESCE(SUM(amount_minor), 0)").
+		Scan(&owed).Error; err != nil {
+		return nil, err
+	}
+
+	minor := min(amount.Minor, payment.Amount.Minor) - owed
+	if minor <= 0 {
+		return nil, nil
+	}
+
+	refund := models.PaymentRefund{
+		PaymentID: payment.ID,
+		Amount:    models.Money{Minor: minor, Currency: payment.Amount.Currency},
+		Status:    models.RefundPending,
+		Reason:    reason,
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+// sendRefunds sends owed refunds to their provider. Refunds that fail are logged and
+// sent again by the expiry sweeper.
+func sendRefunds(refunds []models.PaymentRefund) {
+	for _, refund := range refunds {
+		if err := sendRefund(refund.ID); err != nil {
+			log.Printf("Failed to send refund %d of payment %d, it will be retried: %v", refund.ID, refund.PaymentID, err)
+		}
+	}
+}
+
+// sendRefund asks the provider to make a refund that is owed and records the outcome.
+// The refund's ID is the reference of the request, so a refund that was made but not
+// recorded is not made twice when it is sent again.
+func sendRefund(refundID uint) error {
+	db := database.GetDB()
+
+	var refund models.PaymentRefund
+	if err := db.First(&refund, refundID).Error; err != nil {
+		return err
+	}
+	if refund.Status == models.RefundSucceeded {
+		return nil
+	}
+
+	var payment models.Payment
+	if err := db.First(&payment, refund.PaymentID).Error; err != nil {
+		return err
+	}
+
+	provider, err := payments.Get(payment.Provider)
+	if err != nil {
+		return err
+	}
+
+	made, sendErr := provider.Refund(context.Background(), payments.RefundRequest{
+		IntentID:  payment.ProviderIntentID,
+		Amount:    refund.Amount.Minor,
+		Reference: refundReference(refund.ID),
+	})
+	if sendErr != nil {
+		if err := db.Model(&refund).
+			Where("status <> ?", models.RefundSucceeded).
+			Updates(map[string]interface{}{
+				"status":     models.RefundFailed,
+				"attempts":   gorm.Expr("attempts + 1"),
+				"last_error": sendErr.Error(),
+			}).Error; err != nil {
+			log.Printf("Failed to record the failure of refund %d: %v", refund.ID, err)
+		}
+		if refund.Attempts+1 >= maxRefundAttempts {
+			log.Printf("Refund %d of payment %d failed %d times and is no longer retried", refund.ID, payment.ID, maxRefundAttempts)
+		}
+		return sendErr
+	}
+
+	tx := db.Begin()
+	if err := settleRefund(tx, refund.ID, made.ID); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// RetryRefunds sends owed refunds again that were not made yet, and returns how many
+// were made
+func RetryRefunds(now time.Time) (int, error) {
+	var refunds []models.PaymentRefund
+	if err := database.GetDB().
+		Where("status IN ? AND attempts < ? AND updated_at <= ?",
+			[]models.RefundStatus{models.RefundPending, models.RefundFailed}, maxRefundAttempts, now.Add(-refundRetryDelay)).
+		Order("id ASC").
+		Find(&refunds).Error; err != nil {
+		return 0, err
+	}
+
+	made := 0
+	for _, refund := range refunds {
+		if err := sendRefund(refund.ID); err != nil {
+			log.Printf("Failed to retry refund %d of payment %d: %v", refund.ID, refund.PaymentID, err)
+			continue
+		}
+		made++
+	}
+
+	return made, nil
+}
+
+// settleRefund records that the provider made a refund and adds it to the refunded
+// amount of its payment. Settling a refund again is a no-op.
+func settleRefund(tx *gorm.DB, refundID uint, providerRefundID string) error {
+	var refund models.PaymentRefund
+	if err := tx.First(&refund, refundID).Error; err != nil {
+		return err
+	}
+
+	// Lock the payment before the refund, like where refunds are created
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, refund.PaymentID).Error; err != nil {
+		return err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&refund, refundID).Error; err != nil {
+		return err
+	}
+
+	if refund.Status == models.RefundSucceeded {
+		return nil
+	}
+
+	if err := tx.Model(&refund).Updates(map[string]interface{}{
+		"status":             models.RefundSucceeded,
+		"provider_refund_id": providerRefundID,
+		"last_error":         "",
+	}).Error; err != nil {
+		return err
+	}
+
+	return addRefunded(tx, &payment, refund.Amount.Minor)
+}
+
+// applyProviderRefund records a refund reported by the provider of a payment. Refunds
+// made here are found by their provider ID or reference and settled. Other refunds
+// were made at the provider directly and are recorded as made.
+func applyProviderRefund(tx *gorm.DB, payment *models.Payment, event *payments.Event) error {
+	if event.RefundID == "" {
+		log.Printf("Ignoring refund event %s of payment %d without a refund ID", event.ID, payment.ID)
+		return nil
+	}
+
+	query := tx.Where("payment_id = ? AND provider_refund_id = ?", payment.ID, event.RefundID)
+	if refundID, ok := parseRefundReference(event.RefundReference); ok {
+		query = tx.Where("payment_id = ? AND (provider_refund_id = ? OR id = ?)", payment.ID, event.RefundID, refundID)
+	}
+
+	var refund models.PaymentRefund
+	err := query.First(&refund).Error
+	if err == nil {
+		return settleRefund(tx, refund.ID, event.RefundID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var locked models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, payment.ID).Error; err != nil {
+		return err
+	}
+
+	// Redeliveries of the refund under another event ID find it by now
+	var seen int64
+	if err := tx.Model(&models.PaymentRefund{}).
+		Where("payment_id = ? AND provider_refund_id = ?", payment.ID, event.RefundID).
+		Count(&seen).Error; err != nil {
+		return err
+	}
+	if seen > 0 {
+		return nil
+	}
+
+	minor := event.Amount
+	if minor <= 0 || minor > locked.Amount.Minor-locked.RefundedMinor {
+		minor = locked.Amount.Minor - locked.RefundedMinor
+	}
+	if minor <= 0 {
+		return nil
+	}
+
+	external := models.PaymentRefund{
+		PaymentID:        locked.ID,
+		Amount:           models.Money{Minor: minor, Currency: locked.Amount.Currency},
+		Status:           models.RefundSucceeded,
+		ProviderRefundID: event.RefundID,
+		Reason:           "refunded at the provider",
+	}
+	if err := tx.Create(&external).Error; err != nil {
+		return err
+	}
+
+	return addRefunded(tx, &locked, minor)
+}
+
+// addRefunded adds minor units to the refunded amount of a locked payment and marks it
+// refunded or partially refunded
+func addRefunded(tx *gorm.DB, payment *models.Payment, minor int64) error {
+	payment.RefundedMinor += minor
+	payment.Status = models.PaymentPartiallyRefunded
+	if payment.RefundedMinor >= payment.Amount.Minor {
+		payment.Status = models.PaymentRefunded
+	}
+	return tx.Model(payment).Updates(map[string]interface{}{
+		"refunded_minor": payment.RefundedMinor,
+		"status":         payment.Status,
+	}).Error
+}
+
+// refundReference is the reference a refund is sent to its provider with
+func refundReference(refundID uint) string {
+	return fmt.Sprintf("%s%d", refundReferencePrefix, refundID)
+}
+
+func parseRefundReference(reference string) (uint, bool) {
+	id, ok := strings.CutPrefix(reference, refundReferencePrefix)
+	if !ok {
+		return 0, false
+	}
+	parsed, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(parsed), true
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+)
+
+// CreateSlotHold reserves a session starting at the given time for the user until the
+// configured hold TTL runs out. The session length follows the offering like in
+// CreateBooking; holds on group sessions reserve a single seat.
+func CreateSlotHold(userID, expertID uint, at SessionTime, offeringID uint) (*models.SlotHold, error) {
+	db := database.GetDB()
+
+	expert, err := getExpertByID(db, expertID)
+	if err != nil {
+		return nil, err
+	}
+
+	startAt, err := resolveSessionStart(expert, at)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := resolveSession(db, expert, offeringID)
+	if err != nil {
+		return nil, err
+	}
+	endAt := startAt.Add(session.Duration)
+	sessionKey := session.key(startAt)
+
+	tx := db.Begin()
+
+	expert, err = lockExpert(tx, expertID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := rulesFor(expert).checkBookingWindow(startAt, time.Now()); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if _, err := findAvailabilityWindow(tx, expert, startAt, endAt); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := ensureTimeRangeFree(tx, expert, startAt, endAt, 0, sessionKey); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// The user may not stack several holds on the same time either
+	if err := ensureNoConflictingHold(tx, expert, startAt, endAt, 0, sessionKey); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// A hold on a group session reserves one of its seats
+	if err := ensureSeatAvailable(tx, sessionKey, session.Capacity, userID, 0); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if sessionKey != nil {
+		var ownHolds int64
+		if err := tx.Model(&models.SlotHold{}).
+			Where("session_key = ? AND user_id = ? AND booking_id IS NULL AND expires_at > ?", *sessionKey, userID, time.Now()).
+			Count(&ownHolds).Error; err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if ownHolds > 0 {
+			tx.Rollback()
+			return nil, invalidInput("you already hold a seat in this session")
+		}
+	}
+
+	hold := models.SlotHold{
+		ExpertID:   expertID,
+		UserID:     userID,
+		OfferingID: session.OfferingID,
+		StartAt:    startAt,
+		EndAt:      endAt,
+		ExpiresAt:  time.Now().Add(config.GetConfig().SlotHoldTTL),
+		SessionKey: sessionKey,
+	}
+
+	if err := tx.Create(&hold).Error; err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	return &hold, nil
+}
+
+// ConfirmSlotHold converts the user's active hold on a free session into a booking.
+// Holds on sessions with a price are paid for with CheckoutSlotHold.
+func ConfirmSlotHold(holdID, userID uint) (*models.Booking, error) {
+	hold, err := getActiveSlotHold(database.GetDB(), holdID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := storedSession(database.GetDB(), hold.OfferingID, hold.EndAt.Sub(hold.StartAt))
+	if err != nil {
+		return nil, err
+	}
+
+	return createBooking(newBooking{
+		UserID:   userID,
+		ExpertID: hold.ExpertID,
+		Session:  session,
+		StartAt:  hold.StartAt,
+		EndAt:    hold.EndAt,
+		HoldID:   hold.ID,
+	})
+}
+
+// ReleaseSlotHold gives up the user's hold before it expires
+func ReleaseSlotHold(holdID, userID uint) error {
+	db := database.GetDB()
+
+	result := db.Where("id = ? AND user_id = ? AND booking_id IS NULL", holdID, userID).Delete(&models.SlotHold{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrSlotHoldNotFound
+	}
+
+	return nil
+}
+
+// ExpireSlotHolds deletes holds that ran out without being converted into a booking
+// and returns how many were removed
+func ExpireSlotHolds(now time.Time) (int64, error) {
+	db := database.GetDB()
+
+	result := db.Where("booking_id IS NULL AND expires_at <= ?", now).Delete(&models.SlotHold{})
+	return result.RowsAffected, result.Error
+}
+
+func getActiveSlotHold(db *gorm.DB, holdID, userID uint) (*models.SlotHold, error) {
+	var hold models.SlotHold
+	if err := db.Where("id = ? AND user_id = ?", holdID, userID).First(&hold).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSlotHoldNotFound
+		}
+		return nil, err
+	}
+
+	if hold.BookingID != nil || !time.Now().Before(hold.ExpiresAt) {
+		return nil, ErrSlotHoldExpired
+	}
+
+	return &hold, nil
+}
+
+// consumeSlotHold links an active hold to the booking created from it
+func consumeSlotHold(tx *gorm.DB, holdID, bookingID uint) error {
+	result := tx.Model(&models.SlotHold{}).
+		Where("id = ? AND booking_id IS NULL AND expires_at > ?", holdID, time.Now()).
+		Update("booking_id", bookingID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrSlotHoldExpired
+	}
+
+	return nil
+}
+
+// ensureNoConflictingHold returns ErrTimeSlotUnavailable when another user's active
+// hold clashes with [startAt, endAt) once the expert's buffers are applied. Holds of
+// ownerID are ignored; pass 0 to consider every hold. Holds on the group session
+// sessionKey don't clash. Call it with the expert row locked.
+func ensureNoConflictingHold(tx *gorm.DB, expert *models.Expert, startAt, endAt time.Time, ownerID uint, sessionKey *string) error {
+	rules := rulesFor(expert)
+	margin := rules.maxBuffer()
+
+	var holds []models.SlotHold
+	if err := tx.Select("start_at", "end_at", "session_key").
+		Where("expert_id = ? AND user_id != ? AND booking_id IS NULL AND expires_at > ? AND start_at < ? AND end_at > ?",
+			expert.ID, ownerID, time.Now(), endAt.Add(margin), startAt.Add(-margin)).
+		Find(&holds).Error; err != nil {
+		return err
+	}
+
+	for _, hold := range holds {
+		if sessionKey != nil && hold.SessionKey != nil && *hold.SessionKey == *sessionKey {
+			continue
+		}
+		if rules.conflicts(busyRange{Start: hold.StartAt, End: hold.EndAt}, startAt, endAt) {
+			return ErrTimeSlotUnavailable
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+)
+
+// UpdateUserProfile updates the user's name and time zone. Empty values leave the
+// current value untouched.
+func UpdateUserProfile(userID uint, name, timeZone string) (*models.User, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return nil, errors.New("user not found")
+	}
+
+	if name != "" {
+		user.Name = name
+	}
+	if timeZone != "" {
+		if _, err := loadLocation(timeZone); err != nil {
+			return nil, err
+		}
+		user.TimeZone = timeZone
+	}
+
+	if err := db.Save(&user).Error; err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/devlpr-nitish/appointment-booking-go/internal/config"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/database"
+	"github.com/devlpr-nitish/appointment-booking-go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JoinWaitlist queues the user for a session with the expert at the given time. Only
+// times inside the expert's availability that are currently taken can be waited for.
+func JoinWaitlist(userID, expertID uint, at SessionTime, offeringID uint) (*models.WaitlistEntry, error) {
+	db := database.GetDB()
+
+	expert, err := getExpertByID(db, expertID)
+	if err != nil {
+		return nil, err
+	}
+
+	startAt, err := resolveSessionStart(expert, at)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := resolveSession(db, expert, offeringID)
+	if err != nil {
+		return nil, err
+	}
+	endAt := startAt.Add(session.Duration)
+
+	if err := rulesFor(expert).checkBookingWindow(startAt, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if _, err := findAvailabilityWindow(db, expert, startAt, endAt); err != nil {
+		return nil, err
+	}
+
+	if err := ensureSessionTaken(db, expert, session, startAt, userID); err != nil {
+		return nil, err
+	}
+
+	var existing int64
+	if err := db.Model(&models.WaitlistEntry{}).
+		Where("user_id = ? AND expert_id = ? AND start_at = ? AND status IN ?",
+			userID, expertID, startAt, []models.WaitlistStatus{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}).
+		Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, invalidInput("you are already on the waitlist for this time")
+	}
+
+	entry := models.WaitlistEntry{
+		ExpertID:   expertID,
+		UserID:     userID,
+		OfferingID: session.OfferingID,
+		StartAt:    startAt,
+		EndAt:      endAt,
+		Status:     models.WaitlistStatusWaiting,
+	}
+
+	if err := db.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// GetUserWaitlist returns the user's waiting and offered entries, soonest first
+func GetUserWaitlist(userID uint) ([]models.WaitlistEntry, error) {
+	db := database.GetDB()
+
+	var entries []models.WaitlistEntry
+	if err := db.Where("user_id = ? AND status IN ?", userID,
+		[]models.WaitlistStatus{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}).
+		Order("start_at ASC, id ASC").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// LeaveWaitlist withdraws the user's entry. An outstanding offer is released and
+// passed on to the next user in line.
+func LeaveWaitlist(entryID, userID uint) error {
+	db := database.GetDB()
+
+	var entry models.WaitlistEntry
+	if err := db.Where("id = ? AND user_id = ? AND status IN ?", entryID, userID,
+		[]models.WaitlistStatus{models.WaitlistStatusWaiting, models.WaitlistStatusOffered}).
+		First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWaitlistEntryNotFound
+		}
+		return err
+	}
+
+	tx := db.Begin()
+
+	expert, err := lockExpert(tx, entry.ExpertID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// The entry may have changed while waiting for the lock
+	result := tx.Model(&models.WaitlistEntry{}).
+		Where("id = ? AND status = ?", entry.ID, entry.Status).
+		Update("status", models.WaitlistStatusCancelled)
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		return ErrWaitlistEntryNotFound
+	}
+
+	if entry.Status == models.WaitlistStatusOffered {
+		if err := releaseWaitlistOffer(tx, &entry); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := promoteWaitlist(tx, expert, entry.StartAt, entry.EndAt, time.Now()); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// ExpireWaitlistOffers ends offers that were not claimed in time, passes the time on
+// to the next users in line and returns how many offers expired
+func ExpireWaitlistOffers(now time.Time) (int, error) {
+	db := database.GetDB()
+
+	var expertIDs []uint
+	if err := db.Model(&models.WaitlistEntry{}).
+		Where("status = ? AND offer_expires_at <= ?", models.WaitlistStatusOffered, now).
+		Distinct().Pluck("expert_id", &expertIDs).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, expertID := range expertIDs {
+		tx := db.Begin()
+
+		expert, err := lockExpert(tx, expertID)
+		if err != nil {
+			tx.Rollback()
+			return expired, err
+		}
+
+		var entries []models.WaitlistEntry
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("expert_id = ? AND status = ? AND offer_expires_at <= ?", expertID, models.WaitlistStatusOffered, now).
+			Find(&entries).Error; err != nil {
+			tx.Rollback()
+			return expired, err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			if err := tx.Model(entry).Update("status", models.WaitlistStatusExpired).Error; err != nil {
+				tx.Rollback()
+				return expired, err
+			}
+			if err := releaseWaitlistOffer(tx, entry); err != nil {
+				tx.Rollback()
+				return expired, err
+			}
+			if err := promoteWaitlist(tx, expert, entry.StartAt, entry.EndAt, now); err != nil {
+				tx.Rollback()
+				return expired, err
+			}
+		}
+
+		if err := tx.Commit().Error; err != nil {
+			return expired, err
+		}
+		expired += len(entries)
+	}
+
+	return expired, nil
+}
+
+// offerFreedTime offers [from, to), which a booking just gave up, to the expert's
+// waitlist. Failures are logged; they must not undo the change that freed the time.
+func offerFreedTime(expertID uint, from, to time.Time) {
+	db := database.GetDB()
+	tx := db.Begin()
+
+	expert, err := lockExpert(tx, expertID)
+	if err != nil {
+		tx.Rollback()
+		log.Printf("Failed to offer freed time of expert %d to the waitlist: %v", expertID, err)
+		return
+	}
+
+	if err := promoteWaitlist(tx, expert, from, to, time.Now()); err != nil {
+		tx.Rollback()
+		log.Printf("Failed to offer freed time of expert %d to the waitlist: %v", expertID, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Failed to offer freed time of expert %d to the waitlist: %v", expertID, err)
+	}
+}
+
+// promoteWaitlist offers every waiting entry near [from, to) whose session is now free
+// to its user, first come first served, by placing a slot hold for them that lasts
+// the waitlist offer TTL. Entries that can no longer be booked expire. Call it with
+// the expert row locked.
+func promoteWaitlist(tx *gorm.DB, expert *models.Expert, from, to, now time.Time) error {
+	rules := rulesFor(expert)
+	margin := rules.maxBuffer()
+
+	var entries []models.WaitlistEntry
+	if err := tx.Where("expert_id = ? AND status = ? AND start_at < ? AND end_at > ?",
+		expert.ID, models.WaitlistStatusWaiting, to.Add(margin), from.Add(-margin)).
+		Order("created_at ASC, id ASC").
+		Find(&entries).Error; err != nil {
+		return err
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+
+		// Too late to book the session now
+		if !rules.withinBookingWindow(entry.StartAt, now) {
+			if err := tx.Model(entry).Update("status", models.WaitlistStatusExpired).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		session, err := storedSession(tx, entry.OfferingID, entry.EndAt.Sub(entry.StartAt))
+		if err != nil {
+			return err
+		}
+
+		err = ensureSessionFree(tx, expert, session, entry.StartAt, entry.UserID)
+		if errors.Is(err, ErrTimeSlotUnavailable) || errors.Is(err, ErrSessionFull) {
+			continue
+		}
+		if errors.Is(err, ErrAlreadyInSession) {
+			if err := tx.Model(entry).Update("status", models.WaitlistStatusClaimed).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		offerExpiresAt := now.Add(config.GetConfig().WaitlistOfferTTL)
+		hold := models.SlotHold{
+			ExpertID:   expert.ID,
+			UserID:     entry.UserID,
+			OfferingID: session.OfferingID,
+			StartAt:    entry.StartAt,
+			EndAt:      entry.EndAt,
+			ExpiresAt:  offerExpiresAt,
+			SessionKey: session.key(entry.StartAt),
+		}
+		if err := tx.Create(&hold).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(entry).Updates(map[string]interface{}{
+			"status":           models.WaitlistStatusOffered,
+			"hold_id":          hold.ID,
+			"offer_expires_at": offerExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureSessionFree checks that the user could book the session starting at startAt
+// right now. Call it with the expert row locked.
+func ensureSessionFree(tx *gorm.DB, expert *models.Expert, session sessionType, startAt time.Time, userID uint) error {
+	endAt := startAt.Add(session.Duration)
+	sessionKey := session.key(startAt)
+
+	if err := ensureTimeRangeFree(tx, expert, startAt, endAt, 0, sessionKey); err != nil {
+		return err
+	}
+	if err := ensureNoConflictingHold(tx, expert, startAt, endAt, userID, sessionKey); err != nil {
+		return err
+	}
+	return ensureSeatAvailable(tx, sessionKey, session.Capacity, userID, 0)
+}
+
+// ensureSessionTaken returns an error unless the session is taken, which is when a
+// waitlist makes sense
+func ensureSessionTaken(db *gorm.DB, expert *models.Expert, session sessionType, startAt time.Time, userID uint) error {
+	err := ensureSessionFree(db, expert, session, startAt, userID)
+	switch {
+	case errors.Is(err, ErrTimeSlotUnavailable), errors.Is(err, ErrSessionFull):
+		return nil
+	case err != nil:
+		return err
+	default:
+		return invalidInput("the requested time is available, book it instead")
+	}
+}
+
+// releaseWaitlistOffer deletes the slot hold of an offer unless it was converted
+func releaseWaitlistOffer(tx *gorm.DB, entry *models.WaitlistEntry) error {
+	if entry.HoldID == nil {
+		return nil
+	}
+	return tx.Where("id = ? AND booking_id IS NULL", *entry.HoldID).Delete(&models.SlotHold{}).Error
+}
+
+// claimWaitlistEntries marks the waitlist entries satisfied by a new booking as
+// claimed: the offer whose hold was converted and any entry of the user for the
+// same session. Offers the booking made redundant give up their holds.
+func claimWaitlistEntries(tx *gorm.DB, booking *models.Booking, holdID uint) error {
+	query := tx.Where("status IN ?", []models.WaitlistStatus{models.WaitlistStatusWaiting, models.WaitlistStatusOffered})
+	if holdID != 0 {
+		query = query.Where("(hold_id = ? OR (user_id = ? AND expert_id = ? AND start_at = ?))",
+			holdID, booking.UserID, booking.ExpertID, booking.StartAt)
+	} else {
+		query = query.Where("user_id = ? AND expert_id = ? AND start_at = ?",
+			booking.UserID, booking.ExpertID, booking.StartAt)
+	}
+
+	var entries []models.WaitlistEntry
+	if err := query.Find(&entries).Error; err != nil {
+		return err
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.HoldID != nil && *entry.HoldID != holdID {
+			if err := releaseWaitlistOffer(tx, entry); err != nil {
+				return err
+			}
+		}
+		if err := tx.Model(entry).Update("status", models.WaitlistStatusClaimed).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,15 +1,29 @@
 package utils
 
 import (
+	"regexp"
+
 	"github.com/go-playground/validator/v10"
 )
 
+// clockPattern matches a 24-hour "HH:MM" clock time with a leading zero
+var clockPattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
 type CustomValidator struct {
 	validator *validator.Validate
 }
 
 func NewValidator() *CustomValidator {
-	return &CustomValidator{validator: validator.New()}
+	v := validator.New()
+
+	// clock accepts times such as "09:30" and rejects "9:30" or "24:00"
+	if err := v.RegisterValidation("clock", func(fl validator.FieldLevel) bool {
+		return IsClockTime(fl.Field().String())
+	}); err != nil {
+		panic(err)
+	}
+
+	return &CustomValidator{validator: v}
 }
 
 func (cv *CustomValidator) Validate(i interface{}) error {
@@ -18,3 +32,9 @@ func (cv *CustomValidator) Validate(i interface{}) error {
 	}
 	return nil
 }
+
+// IsClockTime reports whether s is a strict "HH:MM" clock time. Such times sort
+// correctly as strings.
+func IsClockTime(s string) bool {
+	return clockPattern.MatchString(s)
+}